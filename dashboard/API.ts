@@ -29,6 +29,13 @@ export const GetClient = (id : string) =>
       .then((res: AxiosResponse) => resolve(res.data))
       .catch((err: AxiosError) => handleError(reject, err)))
 
+export const GetPushedRequest = (clientID : string, requestURI : string) =>
+  new Promise((resolve, reject) =>
+    axios.get(`${API_ENDPOINT}/auth/par?client_id=${encodeURIComponent(clientID)}` +
+      `&request_uri=${encodeURIComponent(requestURI)}`)
+      .then((res: AxiosResponse) => resolve(res.data))
+      .catch((err: AxiosError) => handleError(reject, err)))
+
 export const DeleteClient = (id : string, token : string) =>
   new Promise((resolve, reject) =>
     axios.delete(`${API_ENDPOINT}/client/${id}`, { headers: {
@@ -56,6 +63,13 @@ export const SignIn = (body: { email: string, password: string }) =>
       .then((res: AxiosResponse) => resolve(res.data))
       .catch((err: AxiosError) => handleError(reject, err)))
 
+export const SignOut = (token: string) =>
+  new Promise((resolve, reject) =>
+    axios.post(`${API_ENDPOINT}/auth/logout`, {}, { headers: {
+      'Authorization': `Bearer ${token}`}})
+      .then((res: AxiosResponse) => resolve(res.data))
+      .catch((err: AxiosError) => handleError(reject, err)))
+
 export const SignUp = (body: {
   first_name: string, last_name: string,
   email: string, password: string, captcha: string
@@ -100,6 +114,15 @@ export const DeleteUser = (id: string, token: string) =>
       .then((res: AxiosResponse) => resolve(res.data))
       .catch((err: AxiosError) => handleError(reject, err)))
 
+export const VerifyDevice = (userCode: string, action: "approve" | "deny",
+  token: string) =>
+  new Promise((resolve, reject) =>
+    axios.post(`${API_ENDPOINT}/auth/device/verify?` +
+      `user_code=${encodeURIComponent(userCode)}&action=${action}`, {},
+      { headers: { 'Authorization': `Bearer ${token}` }})
+      .then((res: AxiosResponse) => resolve(res.data))
+      .catch((err: AxiosError) => handleError(reject, err)))
+
 export const ValidateEmail = (email : string) => {
   if (email.match(/^[\w-\.]+@([\w-]+\.)+[\w-]{2,4}$/)) {
     return true
@@ -108,6 +131,11 @@ export const ValidateEmail = (email : string) => {
 }
 
 export const ValidateClientURLParams = (client : any) => {
+  // Pushed authorization requests only carry client_id and request_uri.
+  if (client.request_uri !== null && client.request_uri !== undefined) {
+    return client.client_id !== null
+  }
+
   let hasDefined = false
   let hasUndefined = false
   const keys = ["response_type", "client_id", "redirect_uri", "state"]
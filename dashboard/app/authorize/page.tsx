@@ -23,7 +23,14 @@ export default function Page() {
     response_type: searchParams.get('response_type'),
     client_id: searchParams.get('client_id'),
     redirect_uri: searchParams.get('redirect_uri'),
-    state: searchParams.get('state')
+    state: searchParams.get('state'),
+    scope: searchParams.get('scope'),
+    nonce: searchParams.get('nonce'),
+    code_challenge: searchParams.get('code_challenge'),
+    code_challenge_method: searchParams.get('code_challenge_method'),
+    request_uri: searchParams.get('request_uri'),
+    response_mode: searchParams.get('response_mode'),
+    resource: searchParams.getAll('resource').join(' ') || null,
   }
 
   const renderForm = () => {
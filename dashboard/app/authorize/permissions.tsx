@@ -4,19 +4,65 @@ import PermissionsForm from '@/components/Permissions/Form'
 import { useState } from 'react'
 import ClientError from './cerror'
 import { Loading } from '@carbon/react'
-import { GetClient } from '@/API'
+import { GetClient, GetPushedRequest } from '@/API'
 
 export type ClientDefinition = {
   response_type: string;
   client_id:     string;
   redirect_uri:  string;
+  redirect_uris?: string[];
   state:         string;
+
+  // Optional parameters forwarded to the authorization endpoint.
+  scope?:                 string | null;
+  nonce?:                 string | null;
+  code_challenge?:        string | null;
+  code_challenge_method?: string | null;
+  request_uri?:           string | null;
+  response_mode?:         string | null;
+
+  // Repeated resource indicators, joined by spaces.
+  resource?:              string | null;
+}
+
+const forwardedParams = [
+  "scope", "nonce", "code_challenge", "code_challenge_method", "request_uri",
+  "response_mode", "resource",
+]
+
+// Whether uri is one of the client's registered redirect URIs. Loopback
+// redirects may use any port (RFC 8252).
+const matchRedirectURI = (registered: string[], uri: string) => {
+  if (registered.includes(uri)) {
+    return true
+  }
+
+  try {
+    const u = new URL(uri)
+    if (u.protocol !== "http:" ||
+      !["localhost", "127.0.0.1", "[::1]"].includes(u.hostname)) {
+      return false
+    }
+
+    return registered.some((r) => {
+      const ru = new URL(r)
+      ru.port = u.port
+      return ru.href === u.href
+    })
+  } catch {
+    return false
+  }
 }
 
 export const Permissions = (props: { client: ClientDefinition }) => {
 
   const client = props.client
 
+  // Pushed authorization requests are validated by the server.
+  if (client.request_uri) {
+    return <PushedPermissions client={client} />
+  }
+
   // Validate response_type.
   if (client.response_type !== "code" && client.response_type !== "token") {
     return <ClientError><p>
@@ -45,11 +91,11 @@ export const Permissions = (props: { client: ClientDefinition }) => {
 
   // Fetch and verify client information.
   const [clientError, setClientError] = useState("")
-  const [clientData, setClientData] = useState<ClientDefinition | null>(null)
+  const [clientData, setClientData] = useState<any>(null)
 
   if (clientData === null && clientError === "") {
     GetClient(props.client.client_id).then((_res) => {
-      let res = (_res as ClientDefinition)
+      let res = (_res as any)
 
       // Ensure response types are same.
       if (client.response_type !== res.response_type) {
@@ -57,13 +103,21 @@ export const Permissions = (props: { client: ClientDefinition }) => {
 	return
       }
 
-      // Ensure redirect_uri's are the same.
-      if (client.redirect_uri !== res.redirect_uri) {
-	setClientError("URL parameter redirect_uri and client redirect_uri do not match.")
+      // Ensure redirect_uri is registered by the client.
+      if (!matchRedirectURI(res.redirect_uris ?? [], client.redirect_uri)) {
+	setClientError("URL parameter redirect_uri is not registered by the client.")
 	return
       }
 
-      setClientData(res)
+      // Only display the requested scope, which the server downscopes to
+      // the client's registered scope.
+      let scope = res.scope
+      if (client.scope) {
+        const requested = client.scope.split(" ")
+        scope = res.scope.filter((s: string) => requested.includes(s))
+      }
+
+      setClientData({ ...res, redirect_uri: client.redirect_uri, scope: scope })
     }).catch((err) => setClientError(err.error_description))
   }
 
@@ -77,5 +131,41 @@ export const Permissions = (props: { client: ClientDefinition }) => {
       </ClientError>
   }
 
-  return <PermissionsForm client={clientData} state={props.client.state}/>
+  const params: { [key: string]: string } = {}
+  for (const key of forwardedParams) {
+    const value = (client as any)[key]
+    if (value) {
+      params[key] = value
+    }
+  }
+
+  return <PermissionsForm client={clientData} state={props.client.state}
+    params={params} />
+}
+
+// PushedPermissions displays a pushed authorization request, whose
+// parameters are stored by the server and only referenced by request_uri.
+// The server returns the pushed scope, downscoped as it will be granted.
+const PushedPermissions = (props: { client: ClientDefinition }) => {
+  const [clientError, setClientError] = useState("")
+  const [clientData, setClientData] = useState<any>(null)
+
+  if (clientData === null && clientError === "") {
+    GetPushedRequest(props.client.client_id, props.client.request_uri as string)
+      .then((res) => setClientData(res))
+      .catch((err) => setClientError(err.error_description))
+  }
+
+  if (clientError === "" && clientData === null) {
+    return (<Loading style={{margin: "auto auto auto auto"}} withOverlay={false} />)
+  }
+
+  if (clientError !== "") {
+    return <ClientError>
+        <p>The client could not be verified. {clientError}</p>
+      </ClientError>
+  }
+
+  return <PermissionsForm client={clientData} state=""
+    params={{ request_uri: props.client.request_uri as string }} />
 }
@@ -0,0 +1,120 @@
+'use client'
+
+import { VerifyDevice } from '@/API'
+import ImageBanner from '@/components/ImageBanner/imagebanner'
+import SigninForm from '@/components/SigninForm'
+import SignupForm from '@/components/SignupForm'
+import AlertBanner from '@/components/AlertBanner'
+import { ArrowRight } from '@carbon/icons-react'
+import { useTheme, Button, Form, Heading, TextInput } from '@carbon/react'
+import { useSearchParams } from 'next/navigation'
+import { useState } from 'react'
+import { useCookies } from 'next-client-cookies'
+import '../authorize/style.scss'
+
+// DeviceForm asks the signed-in user to approve or deny the device
+// authorization request identified by the user code that the device
+// displays (RFC 8628).
+const DeviceForm = (props: { userCode: string, token: string }) => {
+  const headingColor = () => {
+    const { theme } = useTheme()
+    return (theme == "white") ? "black" : "white"
+  }
+
+  const [userCode, setUserCode] = useState(props.userCode)
+  const [hasError, setHasError] = useState("")
+  const [result, setResult] = useState<any>(null)
+
+  const submit = (action: "approve" | "deny") => (e: any) => {
+    e.preventDefault()
+    if (userCode.trim() === "") {
+      setHasError("Please enter the code displayed on your device")
+      return
+    }
+
+    VerifyDevice(userCode, action, props.token).then((res) => {
+      setHasError("")
+      setResult(res)
+    }).catch((err) => setHasError(err.error_description))
+  }
+
+  if (result !== null) {
+    return (
+      <AlertBanner heading={(result.status === "approved") ?
+        "Device Connected" : "Request Denied"}>
+        <p>
+          {(result.status === "approved") ?
+            `'${result.name}' can now access your Open Source Club account. ` :
+            `'${result.name}' was not given access to your account. `}
+          You may return to your device.
+        </p>
+      </AlertBanner>
+    )
+  }
+
+  return (
+    <Form className="form">
+      <Heading className="heading"
+	style={{ marginBottom: "20px", color: headingColor() }}>
+	Connect a Device
+      </Heading>
+      <p style={{ marginBottom: "20px" }}>
+	Enter the code displayed on your device to let it sign in to your
+	Open Source Club account. Only continue if you started signing in
+	on the device yourself.
+      </p>
+      <TextInput
+	id="user_code"
+	style={{ marginBottom: "15px" }}
+	placeholder="ABCD-EFGH"
+	labelText="Device Code"
+	value={userCode}
+	onChange={(e) => setUserCode(e.target.value)}/>
+      <Button type="submit" className="signinform--button" onClick={submit("approve")}>
+	Approve
+	<ArrowRight className="button--arrow" />
+      </Button>
+      <Button className="signinform--button" kind="danger--tertiary"
+	onClick={submit("deny")}>
+	Deny
+      </Button>
+      {
+	(hasError != "") ? (
+	  <p style={{ marginTop: 10, marginBottom: 5, color: 'red' }}>
+	    Error: { hasError }
+	  </p>) : null
+      }
+    </Form>
+  )
+}
+
+export default function Page() {
+  const cookies = useCookies()
+  const token = cookies.get('ows-access-token')
+  const [view, setView] = useState<"signin" | "signup">("signin")
+
+  // Devices link here with the user code prefilled.
+  const searchParams = useSearchParams()
+  const userCode = searchParams.get('user_code') ?? ""
+
+  const renderForm = () => {
+    if (typeof token === "undefined" && view === "signin") {
+      return (<SigninForm setView={setView} />)
+    }
+
+    if (typeof token === "undefined" && view === "signup") {
+      return (<SignupForm setView={setView} />)
+    }
+
+    return (<DeviceForm userCode={userCode} token={token as string} />)
+  }
+
+  return (
+    <div className="loginPage">
+      <div className="loginPage--form">
+	{ renderForm() }
+      </div>
+      <ImageBanner/>
+    </div>
+  )
+}
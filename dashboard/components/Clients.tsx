@@ -29,8 +29,8 @@ const headers = [
     header: 'Type',
   },
   {
-    key: 'redirect_uri',
-    header: 'URI',
+    key: 'redirect_uris',
+    header: 'URIs',
   },
   {
     key: 'scope',
@@ -79,6 +79,8 @@ export default function Clients() {
     response_type: "code",
     redirect_uri: "",
     email: false,
+    profile: false,
+    public_client: false,
   })
 
   const fetchTable = () => {
@@ -92,6 +94,7 @@ export default function Clients() {
           }
         }
         client.scope = text
+        client.redirect_uris = client.redirect_uris.join(", ")
         return client
       }))
 
@@ -150,12 +153,16 @@ export default function Clients() {
       description: createClientForm.name,
       response_type: createClientForm.response_type,
       redirect_uri: createClientForm.redirect_uri,
-      scope: ['public']
+      scope: ['public'],
+      public: createClientForm.public_client && createClientForm.response_type === 'code',
     }
 
     if (createClientForm.email && createClientForm.response_type === 'code')
       form.scope.push('email')
 
+    if (createClientForm.profile && createClientForm.response_type === 'code')
+      form.scope.push('profile')
+
     CreateClient(form, token)
       .then((res) => {
         setCreateClientForm({
@@ -164,6 +171,8 @@ export default function Clients() {
           response_type: "code",
           redirect_uri: "",
           email: false,
+          profile: false,
+          public_client: false,
         })
 
         setClientModal(false)
@@ -248,6 +257,8 @@ export default function Clients() {
                       response_type: createClientForm.response_type,
                       redirect_uri: createClientForm.redirect_uri,
                       email: createClientForm.email,
+                      profile: createClientForm.profile,
+                      public_client: createClientForm.public_client,
                     })}
                   />
                   <TextArea
@@ -262,6 +273,8 @@ export default function Clients() {
                       response_type: createClientForm.response_type,
                       redirect_uri: createClientForm.redirect_uri,
                       email: createClientForm.email,
+                      profile: createClientForm.profile,
+                      public_client: createClientForm.public_client,
                     })}
                   />
                   <Select id="response-type"
@@ -274,6 +287,8 @@ export default function Clients() {
                       response_type: e.target.value,
                       redirect_uri: createClientForm.redirect_uri,
                       email: createClientForm.email,
+                      profile: createClientForm.profile,
+                      public_client: createClientForm.public_client,
                     })}
                   >
                     <SelectItem value="code" text="Authorization code" />
@@ -291,6 +306,8 @@ export default function Clients() {
                       response_type: createClientForm.response_type,
                       redirect_uri: e.target.value,
                       email: createClientForm.email,
+                      profile: createClientForm.profile,
+                      public_client: createClientForm.public_client,
                     })}
                   />
                   <FormGroup legendText="Client Scope"
@@ -305,10 +322,43 @@ export default function Clients() {
                         response_type: createClientForm.response_type,
                         redirect_uri: createClientForm.redirect_uri,
                         email: checked,
+                        profile: createClientForm.profile,
+                        public_client: createClientForm.public_client,
+                      })}
+                    />) : null
+                    }
+                    { (createClientForm.response_type === 'code') ? (
+                    <Checkbox labelText="Profile" id="scope-check-profile"
+                      onChange={ (e, { checked, id }) => setCreateClientForm({
+                        name: createClientForm.name,
+                        description: createClientForm.description,
+                        response_type: createClientForm.response_type,
+                        redirect_uri: createClientForm.redirect_uri,
+                        email: createClientForm.email,
+                        profile: checked,
+                        public_client: createClientForm.public_client,
                       })}
                     />) : null
                     }
                   </FormGroup>
+                  { (createClientForm.response_type === 'code') ? (
+                  <FormGroup legendText="Client Type"
+                    style={{ marginBottom: "15px" }}
+                  >
+                    <Checkbox labelText="Native or browser app (no secret, requires PKCE)"
+                      id="public-client-check"
+                      onChange={ (e, { checked, id }) => setCreateClientForm({
+                        name: createClientForm.name,
+                        description: createClientForm.description,
+                        response_type: createClientForm.response_type,
+                        redirect_uri: createClientForm.redirect_uri,
+                        email: createClientForm.email,
+                        profile: createClientForm.profile,
+                        public_client: checked,
+                      })}
+                    />
+                  </FormGroup>) : null
+                  }
                   <Button id='submit-create-client' type='submit'
                     style={{ display: 'none' }}>
                     Submit
@@ -1,10 +1,11 @@
 'use client'
 
-import { useContext } from 'react'
+import { useContext, useState } from 'react'
 import { UserAvatar, Asleep, Awake, Logout } from '@carbon/icons-react'
 import { useCookies } from 'next-client-cookies'
 import { useRouter } from 'next/navigation'
 import { VERSION } from '@/config'
+import { SignOut, IsAPISuccess } from '@/API'
 
 import { Header, HeaderContainer, HeaderName, HeaderGlobalBar,
   HeaderGlobalAction, SkipToContent, useTheme } from '@carbon/react'
@@ -14,6 +15,10 @@ const NavHeader = (props : { setTheme: Function }) => {
   const cookies = useCookies()
   const token = cookies.get('ows-access-token')
 
+  // Front-channel logout URLs of the clients that the user is signed in
+  // to, loaded in hidden iframes before leaving the page.
+  const [logoutURIs, setLogoutURIs] = useState<string[]>([])
+
   const themeSelector = () => {
     const { theme } = useTheme()
     return (theme == "white") ? (
@@ -30,8 +35,20 @@ const NavHeader = (props : { setTheme: Function }) => {
   }
 
   const onSignout = () => {
-    cookies.remove('ows-access-token')
-    router.push("/authorize")
+    const done = () => {
+      cookies.remove('ows-access-token')
+      router.push("/authorize")
+    }
+
+    SignOut(token as string).then((res : any) => {
+      const uris = IsAPISuccess(res) ? res.frontchannel_logout_uris : []
+      if (!uris || uris.length === 0) {
+        done()
+        return
+      }
+      setLogoutURIs(uris)
+      setTimeout(done, 2000)
+    }).catch(done)
   }
 
   return (
@@ -53,6 +70,11 @@ const NavHeader = (props : { setTheme: Function }) => {
 	    ) : null
 	  }
 	</HeaderGlobalBar>
+	{
+	  logoutURIs.map((uri) => (
+	    <iframe key={uri} src={uri} title="logout" hidden />
+	  ))
+	}
       </Header>
     )}
     />
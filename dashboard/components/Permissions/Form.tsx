@@ -3,11 +3,13 @@
 import './style.scss'
 import { ArrowRight, Wikis } from '@carbon/icons-react'
 import { useTheme, Button, Form, Heading, Accordion, AccordionItem } from '@carbon/react'
-import { PublicScope, EmailScope, ModifyScope } from './scopes'
+import { PublicScope, EmailScope, ProfileScope, ModifyScope } from './scopes'
 import { useCookies } from 'next-client-cookies'
 import { useRouter } from 'next/navigation'
 
-const PermissionsForm = (props: { client: any, state: string }) => {
+const PermissionsForm = (props: {
+  client: any, state: string, params: { [key: string]: string }
+}) => {
   const router = useRouter()
   const cookies = useCookies()
 
@@ -17,9 +19,17 @@ const PermissionsForm = (props: { client: any, state: string }) => {
   }
 
   const onAccept = () => {
+    const params = new URLSearchParams(props.params)
+    if (params.has("request_uri")) {
+      router.push(`http://localhost:8080/auth/authorize?client_id=${props.client.id}` +
+        `&${params.toString()}&assertion=${cookies.get('ows-access-token')}`)
+      return
+    }
+
     router.push(`http://localhost:8080/auth/authorize?response_type=${props.client.response_type}` +
       `&client_id=${props.client.id}&redirect_uri=${encodeURIComponent(props.client.redirect_uri)}` +
-      `&state=${props.state}&assertion=${cookies.get('ows-access-token')}`)
+      `&state=${props.state}&assertion=${cookies.get('ows-access-token')}` +
+      (params.toString() === "" ? "" : `&${params.toString()}`))
   }
 
   const onReject = () => {
@@ -42,6 +52,7 @@ const PermissionsForm = (props: { client: any, state: string }) => {
 	</AccordionItem>
 	{ (!props.client.scope?.includes("public")) ? null : (<PublicScope />) }
 	{ (!props.client.scope?.includes("email")) ? null : (<EmailScope />) }
+	{ (!props.client.scope?.includes("profile")) ? null : (<ProfileScope />) }
 	{ (!props.client.scope?.includes("modify")) ? null : (<ModifyScope />) }
       </Accordion>
       <p style={{ marginTop: "20px", marginBottom: "20px" }}>
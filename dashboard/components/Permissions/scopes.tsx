@@ -1,4 +1,4 @@
-import { Wikis, Email, Edit } from '@carbon/icons-react'
+import { Wikis, Email, Edit, UserProfile } from '@carbon/icons-react'
 import { AccordionItem } from '@carbon/react'
 import './style.scss'
 
@@ -24,6 +24,17 @@ export const EmailScope = () =>
     <p style={{ fontSize: 13 }}> Read your email address </p>
   </AccordionItem>
 
+const ProfileScopeTitle = () =>
+  <p>
+    <UserProfile className="perm--scope-icon" />
+    {" Profile"}
+  </p>
+
+export const ProfileScope = () =>
+  <AccordionItem title={ProfileScopeTitle()}>
+    <p style={{ fontSize: 13 }}> Read your name as part of your profile </p>
+  </AccordionItem>
+
 const ModifyScopeTitle = () =>
   <p>
     <Edit className="perm--scope-icon" />
@@ -74,6 +74,7 @@ export default function Users() {
       clients_read: false,
       clients_delete: false,
       clients_create: false,
+      clients_introspect: false,
       users_read: false,
       users_delete: false,
       users_update: false,
@@ -152,6 +153,7 @@ export default function Users() {
         clients_read: scope.includes("clients.read"),
         clients_delete: scope.includes("clients.delete"),
         clients_create: scope.includes("clients.create"),
+        clients_introspect: scope.includes("clients.introspect"),
         users_read: scope.includes("users.read"),
         users_delete: scope.includes("users.delete"),
         users_update: scope.includes("users.update"),
@@ -179,6 +181,10 @@ export default function Users() {
       realms.push("clients.create")
     }
 
+    if (modifyUserForm.scope.clients_introspect) {
+      realms.push("clients.introspect")
+    }
+
     if (modifyUserForm.scope.users_read) {
       realms.push("users.read")
     }
@@ -207,6 +213,7 @@ export default function Users() {
             clients_read: false,
             clients_delete: false,
             clients_create: false,
+            clients_introspect: false,
             users_read: false,
             users_delete: false,
             users_update: false,
@@ -320,6 +327,7 @@ export default function Users() {
                         clients_read: checked,
                         clients_delete: modifyUserForm.scope.clients_delete,
                         clients_create: modifyUserForm.scope.clients_create,
+                        clients_introspect: modifyUserForm.scope.clients_introspect,
                         users_read: modifyUserForm.scope.users_read,
                         users_delete: modifyUserForm.scope.users_delete,
                         users_update: modifyUserForm.scope.users_update,
@@ -336,6 +344,7 @@ export default function Users() {
                         clients_read: modifyUserForm.scope.clients_read,
                         clients_delete: checked,
                         clients_create: modifyUserForm.scope.clients_create,
+                        clients_introspect: modifyUserForm.scope.clients_introspect,
                         users_read: modifyUserForm.scope.users_read,
                         users_delete: modifyUserForm.scope.users_delete,
                         users_update: modifyUserForm.scope.users_update,
@@ -352,6 +361,24 @@ export default function Users() {
                         clients_read: modifyUserForm.scope.clients_read,
                         clients_delete: modifyUserForm.scope.clients_delete,
                         clients_create: checked,
+                        clients_introspect: modifyUserForm.scope.clients_introspect,
+                        users_read: modifyUserForm.scope.users_read,
+                        users_delete: modifyUserForm.scope.users_delete,
+                        users_update: modifyUserForm.scope.users_update,
+                      },
+                    })}
+                  />
+                  <Checkbox labelText="clients.introspect" id="realm-clients-introspect"
+                    checked={modifyUserForm.scope.clients_introspect}
+                    onChange = {(e, { checked, id }) => setModifyUserForm({
+                      id: modifyUserForm.id,
+                      first_name: modifyUserForm.first_name,
+                      last_name: modifyUserForm.last_name,
+                      scope: {
+                        clients_read: modifyUserForm.scope.clients_read,
+                        clients_delete: modifyUserForm.scope.clients_delete,
+                        clients_create: modifyUserForm.scope.clients_create,
+                        clients_introspect: checked,
                         users_read: modifyUserForm.scope.users_read,
                         users_delete: modifyUserForm.scope.users_delete,
                         users_update: modifyUserForm.scope.users_update,
@@ -368,6 +395,7 @@ export default function Users() {
                         clients_read: modifyUserForm.scope.clients_read,
                         clients_delete: modifyUserForm.scope.clients_delete,
                         clients_create: modifyUserForm.scope.clients_create,
+                        clients_introspect: modifyUserForm.scope.clients_introspect,
                         users_read: checked,
                         users_delete: modifyUserForm.scope.users_delete,
                         users_update: modifyUserForm.scope.users_update,
@@ -384,6 +412,7 @@ export default function Users() {
                         clients_read: modifyUserForm.scope.clients_read,
                         clients_delete: modifyUserForm.scope.clients_delete,
                         clients_create: modifyUserForm.scope.clients_create,
+                        clients_introspect: modifyUserForm.scope.clients_introspect,
                         users_read: modifyUserForm.scope.users_read,
                         users_delete: checked,
                         users_update: modifyUserForm.scope.users_update,
@@ -400,6 +429,7 @@ export default function Users() {
                         clients_read: modifyUserForm.scope.clients_read,
                         clients_delete: modifyUserForm.scope.clients_delete,
                         clients_create: modifyUserForm.scope.clients_create,
+                        clients_introspect: modifyUserForm.scope.clients_introspect,
                         users_read: modifyUserForm.scope.users_read,
                         users_delete: modifyUserForm.scope.users_delete,
                         users_update: checked,
@@ -8,12 +8,30 @@ import (
 
 // Config encapsulates environment variables.
 type Config struct {
-	GIN_MODE         string
-	MONGO_URI        string
-	DB_NAME          string
-	NOTIF_EMAIL_ADDR string
-	PORT             string
-	WEBSMTP          string
+	GIN_MODE            string
+	MONGO_URI           string
+	DB_NAME             string
+	NOTIF_EMAIL_ADDR    string
+	PORT                string
+	WEBSMTP             string
+	ISSUER              string
+	FRONTEND            string
+	SIGNING_KEY         string
+	ACCESS_TOKEN_FORMAT string
+	DPOP_NONCE_KEY      string
+	LEGACY_TOKEN_GET    bool
+
+	// Headers that the ingress forwards client certificates and their
+	// verification result in, e.g. ssl-client-cert and ssl-client-verify.
+	CLIENT_CERT_HEADER        string
+	CLIENT_CERT_VERIFY_HEADER string
+
+	// PEM files that the server serves TLS with, instead of plain HTTP,
+	// and that client certificates of direct connections are verified
+	// against.
+	TLS_CERT      string
+	TLS_KEY       string
+	TLS_CLIENT_CA string
 }
 
 // GetDefaultConfig populates a Config instance with default configuration
@@ -25,6 +43,17 @@ func GetDefaultConfig() (c Config) {
 	c.NOTIF_EMAIL_ADDR = "no-reply.notifications@ufosc.org"
 	c.PORT = "8080"
 	c.WEBSMTP = "http://localhost:3001"
+	c.ISSUER = "http://localhost:8080"
+	c.FRONTEND = "http://localhost:3000"
+	c.SIGNING_KEY = ""
+	c.ACCESS_TOKEN_FORMAT = "opaque"
+	c.DPOP_NONCE_KEY = ""
+	c.LEGACY_TOKEN_GET = false
+	c.CLIENT_CERT_HEADER = ""
+	c.CLIENT_CERT_VERIFY_HEADER = ""
+	c.TLS_CERT = ""
+	c.TLS_KEY = ""
+	c.TLS_CLIENT_CA = ""
 	return c
 }
 
@@ -54,6 +83,55 @@ func GetConfig() Config {
 	if websmtp := os.Getenv("WEBSMTP"); websmtp != "" {
 		c.WEBSMTP = websmtp
 	}
+	if issuer := os.Getenv("ISSUER"); issuer != "" {
+		c.ISSUER = issuer
+	}
+	if frontend := os.Getenv("FRONTEND"); frontend != "" {
+		c.FRONTEND = frontend
+	}
+	if key := os.Getenv("SIGNING_KEY"); key != "" {
+		c.SIGNING_KEY = key
+	}
+	if format := os.Getenv("ACCESS_TOKEN_FORMAT"); format == "opaque" || format == "jwt" {
+		c.ACCESS_TOKEN_FORMAT = format
+	}
+	if key := os.Getenv("DPOP_NONCE_KEY"); key != "" {
+		c.DPOP_NONCE_KEY = key
+	}
+	if legacy := os.Getenv("LEGACY_TOKEN_GET"); legacy == "true" {
+		c.LEGACY_TOKEN_GET = true
+	}
+	if header := os.Getenv("CLIENT_CERT_HEADER"); header != "" {
+		c.CLIENT_CERT_HEADER = header
+	}
+	if header := os.Getenv("CLIENT_CERT_VERIFY_HEADER"); header != "" {
+		c.CLIENT_CERT_VERIFY_HEADER = header
+	}
+	if cert := os.Getenv("TLS_CERT"); cert != "" {
+		c.TLS_CERT = cert
+	}
+	if key := os.Getenv("TLS_KEY"); key != "" {
+		c.TLS_KEY = key
+	}
+	if ca := os.Getenv("TLS_CLIENT_CA"); ca != "" {
+		c.TLS_CLIENT_CA = ca
+	}
+
+	if (c.TLS_CERT == "") != (c.TLS_KEY == "") {
+		log.Fatal("TLS_CERT and TLS_KEY must be set together")
+	}
+
+	// Deployments, which configure their issuer, must configure their
+	// keys. Random keys invalidate tokens and nonces on every restart and
+	// differ between replicas.
+	if os.Getenv("ISSUER") != "" {
+		if c.SIGNING_KEY == "" {
+			log.Fatal("SIGNING_KEY is required when ISSUER is set")
+		}
+		if c.DPOP_NONCE_KEY == "" {
+			log.Fatal("DPOP_NONCE_KEY is required when ISSUER is set")
+		}
+	}
 
 	return c
 }
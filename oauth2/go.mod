@@ -18,6 +18,7 @@ require (
 	github.com/joho/godotenv v1.5.1
 	github.com/ufosc/OpenWebServices/pkg/authapi v0.0.0-00010101000000-000000000000
 	github.com/ufosc/OpenWebServices/pkg/authmw v0.0.0-00010101000000-000000000000
+	github.com/ufosc/OpenWebServices/pkg/common v0.0.0-00010101000000-000000000000
 )
 
 require (
@@ -46,7 +47,6 @@ require (
 	github.com/pelletier/go-toml/v2 v2.1.0 // indirect
 	github.com/twitchyliquid64/golang-asm v0.15.1 // indirect
 	github.com/ufosc/OpenWebServices/pkg/authdb v0.0.0-00010101000000-000000000000 // indirect
-	github.com/ufosc/OpenWebServices/pkg/common v0.0.0-00010101000000-000000000000 // indirect
 	github.com/ufosc/OpenWebServices/pkg/websmtp v0.0.0-00010101000000-000000000000 // indirect
 	github.com/ugorji/go/codec v1.2.11 // indirect
 	github.com/wagslane/go-password-validator v0.3.0 // indirect
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/ufosc/OpenWebServices/pkg/authapi"
 	"github.com/ufosc/OpenWebServices/pkg/authmw"
+	"github.com/ufosc/OpenWebServices/pkg/common"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 )
 
@@ -21,15 +27,70 @@ func main() {
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"POST, PUT, GET, DELETE"},
 		AllowHeaders:     []string{"*"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "WWW-Authenticate", "DPoP-Nonce"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
+	// Client certificates authenticate mTLS clients and bind their
+	// tokens. They reach the server over direct TLS connections, or
+	// forwarded by a proxy if its headers are configured.
+	var clientCAs *x509.CertPool
+	if config.TLS_CLIENT_CA != "" {
+		data, err := os.ReadFile(config.TLS_CLIENT_CA)
+		if err != nil {
+			panic(err)
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(data) {
+			panic("no certificates found in " + config.TLS_CLIENT_CA)
+		}
+	}
+
+	r.Use(authmw.ClientCert(authmw.CertConfig{
+		Header:       config.CLIENT_CERT_HEADER,
+		VerifyHeader: config.CLIENT_CERT_VERIFY_HEADER,
+		ClientCAs:    clientCAs,
+	}))
+
+	clientCertificates := config.TLS_CERT != "" ||
+		(config.CLIENT_CERT_HEADER != "" && config.CLIENT_CERT_VERIFY_HEADER != "")
+
+	// Token signing key. A random key is generated for local development,
+	// when ISSUER is not set, which invalidates issued JWTs on restart.
+	signingKey, err := common.GenerateSigningKey()
+	if config.SIGNING_KEY != "" {
+		signingKey, err = common.LoadSigningKey(config.SIGNING_KEY)
+	}
+
+	if err != nil {
+		panic(err)
+	}
+
+	// DPoP nonce key, shared by the token route and route middleware. A
+	// random key is generated for local development, when ISSUER is not
+	// set.
+	nonceKey := []byte(config.DPOP_NONCE_KEY)
+	if len(nonceKey) == 0 {
+		nonceKey = make([]byte, 32)
+		if _, err := rand.Read(nonceKey); err != nil {
+			panic(err)
+		}
+	}
+
 	// API controller.
 	api, err := authapi.CreateAPIController(config.MONGO_URI,
 		config.DB_NAME, config.NOTIF_EMAIL_ADDR,
-		config.WEBSMTP)
+		config.WEBSMTP, authapi.Config{
+			Issuer:             config.ISSUER,
+			Frontend:           config.FRONTEND,
+			SigningKey:         signingKey,
+			AccessTokenFormat:  config.ACCESS_TOKEN_FORMAT,
+			DPoPNonceKey:       nonceKey,
+			ClientCertificates: clientCertificates,
+			LegacyTokenGET:     config.LEGACY_TOKEN_GET,
+		})
 
 	if err != nil {
 		panic(err)
@@ -37,57 +98,158 @@ func main() {
 
 	defer api.Stop()
 
+	// Route authentication middleware. JWT access tokens are verified
+	// using the server's public key and checked for revocation.
+	keys := common.JWKSet{Keys: []common.JWK{signingKey.JWK()}}
+	x := func(mw authmw.Config) gin.HandlerFunc {
+		mw.Keys = keys
+		mw.Issuer = strings.TrimSuffix(config.ISSUER, "/")
+		mw.DPoPNonceKey = nonceKey
+		mw.ResourceURL = mw.Issuer
+		mw.Audience = mw.Issuer
+		return authmw.X(api.DB(), mw)
+	}
+
 	// Auth.
 	r.POST("/auth/signup", api.SignUpRoute())
 	r.POST("/auth/signin", api.SignInRoute())
 	r.GET("/auth/verify/:ref", api.VerifyEmailRoute())
+	r.POST("/auth/logout", x(authmw.Config{
+		Scope: []string{"dashboard"},
+	}), api.SignOutRoute())
+	r.POST("/auth/logout-all", x(authmw.Config{
+		Scope: []string{"dashboard"},
+	}), api.LogoutAllRoute())
+	r.POST("/auth/token", api.TokenRoute())
 	r.GET("/auth/token", api.TokenRoute())
+	r.POST("/auth/introspect", authmw.C(api.DB(), config.ISSUER),
+		api.IntrospectionRoute())
+	r.POST("/auth/revoke", authmw.C(api.DB(), config.ISSUER),
+		api.RevocationRoute())
+	r.POST("/auth/device", api.DeviceAuthorizationRoute())
+	r.POST("/auth/device/verify", authmw.A(api.DB()),
+		api.DeviceVerificationRoute())
 	r.GET("/auth/authorize", authmw.A(api.DB()),
 		api.AuthorizationRoute())
+	r.POST("/auth/par", authmw.C(api.DB(), config.ISSUER),
+		api.PushedAuthorizationRoute())
+	r.GET("/auth/par", api.GetPushedRequestRoute())
+
+	// OpenID Connect.
+	r.GET("/.well-known/oauth-authorization-server",
+		api.AuthorizationServerMetadataRoute())
+	r.GET("/.well-known/openid-configuration", api.OpenIDConfigurationRoute())
+	r.GET("/.well-known/jwks.json", api.JWKSRoute())
+	r.GET("/userinfo", x(authmw.Config{}),
+		api.UserInfoRoute())
+
+	r.POST("/userinfo", x(authmw.Config{}),
+		api.UserInfoRoute())
 
 	// Resources.
 	r.GET("/client/:id", api.GetClientRoute())
-	r.GET("/user", authmw.X(api.DB(), authmw.Config{}),
+	r.GET("/user", x(authmw.Config{}),
 		api.GetUserRoute())
 
-	r.PUT("/user", authmw.X(api.DB(), authmw.Config{
+	r.GET("/user/apps", x(authmw.Config{
+		Scope: []string{"dashboard"},
+	}), api.GetUserAppsRoute())
+
+	r.DELETE("/user/apps/:client_id", x(authmw.Config{
+		Scope: []string{"dashboard"},
+	}), api.RevokeUserAppRoute())
+
+	r.PUT("/user", x(authmw.Config{
 		Scope: []string{"users.update"},
 	}), api.UpdateUserRoute())
 
-	r.PUT("/user/realms/:id", authmw.X(api.DB(), authmw.Config{
+	r.PUT("/user/realms/:id", x(authmw.Config{
 		Scope:  []string{"users.update"},
 		Realms: []string{"users.update"},
 	}), api.UpdateUserRealmsRoute())
 
-	r.DELETE("/user/:id", authmw.X(api.DB(), authmw.Config{
+	r.DELETE("/user/:id", x(authmw.Config{
 		Scope:  []string{"users.delete"},
 		Realms: []string{"users.delete"},
 	}), api.DeleteUserRoute())
 
-	r.GET("/users", authmw.X(api.DB(), authmw.Config{
+	r.POST("/user/:id/logout", x(authmw.Config{
+		Scope:  []string{"users.update"},
+		Realms: []string{"users.update"},
+	}), api.LogoutUserRoute())
+
+	r.GET("/users", x(authmw.Config{
 		Scope:  []string{"users.read"},
 		Realms: []string{"users.read"},
 	}), api.GetUsersRoute())
 
-	r.POST("/client", authmw.X(api.DB(), authmw.Config{
+	r.POST("/client", x(authmw.Config{
 		Scope:  []string{"clients.create"},
 		Realms: []string{"clients.create"},
 	}), api.CreateClientRoute())
 
-	r.GET("/clients", authmw.X(api.DB(), authmw.Config{
+	r.GET("/clients", x(authmw.Config{
 		Scope:  []string{"clients.read"},
 		Realms: []string{"clients.read"},
 	}), api.GetClientsRoute())
 
-	r.DELETE("/client/:id", authmw.X(api.DB(), authmw.Config{
+	r.POST("/client/:id/secret", x(authmw.Config{
+		Scope: []string{"clients.create"},
+	}), api.RotateClientSecretRoute())
+
+	r.DELETE("/client/:id", x(authmw.Config{
 		Scope:  []string{"clients.delete"},
 		Realms: []string{"clients.delete"},
 	}), api.DeleteClientRoute())
 
+	r.POST("/resource", x(authmw.Config{
+		Scope:  []string{"clients.create"},
+		Realms: []string{"clients.create"},
+	}), api.CreateResourceRoute())
+
+	r.GET("/resources", x(authmw.Config{
+		Scope:  []string{"clients.read"},
+		Realms: []string{"clients.read"},
+	}), api.GetResourcesRoute())
+
+	r.DELETE("/resource/:id", x(authmw.Config{
+		Scope: []string{"clients.delete"},
+	}), api.DeleteResourceRoute())
+
+	// Dynamic client registration.
+	r.POST("/client/initial-token", x(authmw.Config{
+		Scope:  []string{"clients.create"},
+		Realms: []string{"clients.create"},
+	}), api.CreateInitialTokenRoute())
+
+	r.POST("/register", api.RegisterClientRoute())
+	r.GET("/register/:id", api.GetRegistrationRoute())
+	r.PUT("/register/:id", api.UpdateRegistrationRoute())
+	r.DELETE("/register/:id", api.DeleteRegistrationRoute())
+
 	// Status.
 	r.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, "ok")
 	})
 
-	r.Run("0.0.0.0:" + config.PORT)
+	addr := "0.0.0.0:" + config.PORT
+	if config.TLS_CERT == "" {
+		r.Run(addr)
+		return
+	}
+
+	// Client certificates are requested but verified by ClientCert, so
+	// that self-signed certificates are accepted too.
+	server := &http.Server{
+		Addr:    addr,
+		Handler: r,
+		TLSConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ClientAuth: tls.RequestClientCert,
+		},
+	}
+
+	if err := server.ListenAndServeTLS(config.TLS_CERT, config.TLS_KEY); err != nil {
+		panic(err)
+	}
 }
@@ -0,0 +1,102 @@
+package authapi
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/common"
+	"strings"
+)
+
+// Access token formats.
+const (
+	TokenFormatOpaque = "opaque"
+	TokenFormatJWT    = "jwt"
+)
+
+// createAccess saves an access token to the database and returns the
+// token string to hand out to the client: the token ID for opaque tokens,
+// or a signed JWT (whose "jti" is the token ID) if configured.
+func (cntrl *DefaultAPIController) createAccess(token authdb.TokenModel) (
+	string, error) {
+	id, err := cntrl.db.Tokens().CreateAccess(token)
+	if err != nil || cntrl.config.AccessTokenFormat != TokenFormatJWT {
+		return id, err
+	}
+
+	jwt, err := cntrl.signAccess(token)
+	if err != nil {
+		cntrl.db.Tokens().DeleteAccessByID(id)
+		return "", err
+	}
+
+	return jwt, nil
+}
+
+// signAccess encodes token as a self-contained JWT access token, which
+// lets authmw verify it without loading the user or client.
+func (cntrl *DefaultAPIController) signAccess(token authdb.TokenModel) (
+	string, error) {
+	scope, err := cntrl.tokenScope(token)
+	if err != nil {
+		return "", err
+	}
+
+	claims := common.AccessTokenClaims{
+		Issuer:   cntrl.config.Issuer,
+		Subject:  token.ClientID,
+		Audience: token.Audience,
+		ClientID: token.ClientID,
+		Scope:    strings.Join(scope, " "),
+		Actor:    common.NewActorClaims(token.Actors),
+		Expiry:   token.CreatedAt + token.TTL,
+		IssuedAt: token.CreatedAt,
+		ID:       token.ID,
+	}
+
+	if token.JKT != "" || token.X5T != "" {
+		claims.Confirm = &common.Confirm{JKT: token.JKT, X5T: token.X5T}
+	}
+
+	// Realms are captured at issuance, so changes to a user's realms
+	// only apply to new tokens.
+	if token.UserID != "" {
+		user, err := cntrl.db.Users().FindByID(token.UserID)
+		if err != nil {
+			return "", err
+		}
+		claims.Subject = user.ID
+		claims.Realms = user.Realms
+	}
+
+	return cntrl.config.SigningKey.Sign(common.AccessTokenType, claims)
+}
+
+// accessTokenID returns the database ID of the access token string
+// presented by a client. Returns an empty string for JWTs that were not
+// signed by this server.
+func (cntrl *DefaultAPIController) accessTokenID(token string) string {
+	if !common.IsJWT(token) {
+		return token
+	}
+
+	var claims common.AccessTokenClaims
+	_, err := common.VerifyJWT(token, cntrl.config.SigningKey.Key.Public(), &claims)
+	if err != nil {
+		return ""
+	}
+
+	return claims.ID
+}
+
+// currentUser returns the user authenticated by authmw. Users decoded from
+// JWT access tokens only carry their ID and realms, so their full profile
+// is loaded from the database.
+func (cntrl *DefaultAPIController) currentUser(c *gin.Context) (
+	authdb.UserModel, error) {
+	userAny, _ := c.Get("user")
+	user, _ := userAny.(authdb.UserModel)
+	if user.Email != "" {
+		return user, nil
+	}
+	return cntrl.db.Users().FindByID(user.ID)
+}
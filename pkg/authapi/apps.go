@@ -0,0 +1,159 @@
+package authapi
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/authmw"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// connectedApp is a client that a user has authorized and that still holds
+// live tokens on their behalf.
+type connectedApp struct {
+	ClientID          string   `json:"client_id"`
+	Name              string   `json:"name"`
+	Description       string   `json:"description"`
+	Scope             []string `json:"scope"`
+	FirstAuthorizedAt int64    `json:"first_authorized_at"`
+	LastAuthorizedAt  int64    `json:"last_authorized_at"`
+}
+
+// GetUserAppsRoute lists the clients that the authenticated user holds
+// live refresh or access tokens for. The dashboard is not listed.
+func (cntrl *DefaultAPIController) GetUserAppsRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userAny, _ := c.Get("user")
+		user, _ := userAny.(authdb.UserModel)
+
+		tokens, err := cntrl.db.Tokens().FindByUser(user.ID, time.Now().Unix())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "failed to fetch documents from server",
+			})
+			return
+		}
+
+		// Summarize the scope and issue times of tokens per client.
+		// Recorded consents take precedence below.
+		apps := map[string]*connectedApp{}
+		ids := []string{}
+		for _, token := range tokens {
+			if token.ClientID == authmw.DashboardClient.ID {
+				continue
+			}
+
+			app, ok := apps[token.ClientID]
+			if !ok {
+				app = &connectedApp{
+					ClientID:          token.ClientID,
+					Scope:             []string{},
+					FirstAuthorizedAt: token.CreatedAt,
+				}
+				apps[token.ClientID] = app
+				ids = append(ids, token.ClientID)
+			}
+
+			for _, value := range token.Scope {
+				if !hasScope(app.Scope, value) {
+					app.Scope = append(app.Scope, value)
+				}
+			}
+
+			if token.CreatedAt < app.FirstAuthorizedAt {
+				app.FirstAuthorizedAt = token.CreatedAt
+			}
+
+			if token.CreatedAt > app.LastAuthorizedAt {
+				app.LastAuthorizedAt = token.CreatedAt
+			}
+		}
+
+		clients, err := cntrl.db.Clients().FindByIDs(ids)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "failed to fetch documents from server",
+			})
+			return
+		}
+
+		consents, err := cntrl.db.Consents().FindByUser(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "failed to fetch documents from server",
+			})
+			return
+		}
+
+		// Consents record the scope that the user granted and when they
+		// did so, which replace the token summary.
+		for _, consent := range consents {
+			if app, ok := apps[consent.ClientID]; ok {
+				app.Scope = consent.Scope
+				app.FirstAuthorizedAt = consent.CreatedAt
+				app.LastAuthorizedAt = consent.UpdatedAt
+			}
+		}
+
+		result := []connectedApp{}
+		for _, client := range clients {
+			app := apps[client.ID]
+			app.Name = client.Name
+			app.Description = client.Description
+			result = append(result, *app)
+		}
+
+		sort.Slice(result, func(i, j int) bool {
+			return result[i].LastAuthorizedAt > result[j].LastAuthorizedAt
+		})
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "success",
+			"count":   len(result),
+			"apps":    result,
+		})
+	}
+}
+
+// RevokeUserAppRoute withdraws the authenticated user's authorization of a
+// client, revoking every token that the client holds on their behalf and
+// deleting the consent that they recorded for it.
+func (cntrl *DefaultAPIController) RevokeUserAppRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userAny, _ := c.Get("user")
+		user, _ := userAny.(authdb.UserModel)
+
+		clientID := c.Param("client_id")
+		if clientID == authmw.DashboardClient.ID {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_request",
+				"error_description": "use /auth/logout-all to sign out of the dashboard",
+			})
+			return
+		}
+
+		if err := cntrl.db.Tokens().DeleteByUserClient(user.ID, clientID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "could not revoke access at this time, please try again later",
+			})
+			return
+		}
+
+		if err := cntrl.db.Consents().Delete(user.ID, clientID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "could not revoke access at this time, please try again later",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "access revoked successfully",
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package authapi
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/authmw"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testConsents struct {
+	authdb.ConsentController
+	consents []authdb.ConsentModel
+	deleted  []string
+}
+
+func (cc *testConsents) FindByUser(userID string) ([]authdb.ConsentModel, error) {
+	consents := []authdb.ConsentModel{}
+	for _, consent := range cc.consents {
+		if consent.UserID == userID {
+			consents = append(consents, consent)
+		}
+	}
+	return consents, nil
+}
+
+func (cc *testConsents) Delete(userID, clientID string) error {
+	cc.deleted = append(cc.deleted, userID+":"+clientID)
+	return nil
+}
+
+func TestGetUserApps(t *testing.T) {
+	now := time.Now().Unix()
+	db := newTestDB()
+	db.consents = &testConsents{consents: []authdb.ConsentModel{{
+		UserID:    "user",
+		ClientID:  "client",
+		Scope:     []string{"public", "email"},
+		CreatedAt: now - 60,
+		UpdatedAt: now - 30,
+	}}}
+	db.clients.clients["client"] = authdb.ClientModel{ID: "client", Name: "Client"}
+	db.tokens.access["token"] = authdb.TokenModel{
+		ID: "token", ClientID: "client", UserID: "user",
+		Scope: []string{"public"}, CreatedAt: now, TTL: 1200,
+	}
+	db.tokens.access["dashboard"] = authdb.TokenModel{
+		ID: "dashboard", ClientID: authmw.DashboardClient.ID, UserID: "user",
+		CreatedAt: now, TTL: 1200,
+	}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/user/apps", nil)
+	c.Set("user", authdb.UserModel{ID: "user"})
+	(&DefaultAPIController{db: db}).GetUserAppsRoute()(c)
+
+	var res struct {
+		Apps []connectedApp `json:"apps"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Apps) != 1 || res.Apps[0].ClientID != "client" {
+		t.Fatalf("expected only the client to be listed, got %s", w.Body.String())
+	}
+
+	app := res.Apps[0]
+	if len(app.Scope) != 2 || app.LastAuthorizedAt != now-30 {
+		t.Fatalf("recorded consent did not replace the token summary: %+v", app)
+	}
+}
+
+func TestRevokeUserApp(t *testing.T) {
+	consents := &testConsents{}
+	db := newTestDB()
+	db.consents = consents
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodDelete, "/user/apps/client", nil)
+	c.Params = gin.Params{{Key: "client_id", Value: "client"}}
+	c.Set("user", authdb.UserModel{ID: "user"})
+	(&DefaultAPIController{db: db}).RevokeUserAppRoute()(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("revocation failed: %s", w.Body.String())
+	}
+
+	if len(db.tokens.revoked) != 1 || len(consents.deleted) != 1 {
+		t.Fatalf("tokens revoked %v and consents deleted %v",
+			db.tokens.revoked, consents.deleted)
+	}
+}
+
+func TestRevokeUserAppDashboard(t *testing.T) {
+	consents := &testConsents{}
+	db := newTestDB()
+	db.consents = consents
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodDelete, "/user/apps/dashboard", nil)
+	c.Params = gin.Params{{Key: "client_id", Value: authmw.DashboardClient.ID}}
+	c.Set("user", authdb.UserModel{ID: "user"})
+	(&DefaultAPIController{db: db}).RevokeUserAppRoute()(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("dashboard access should not be revocable here, got %d", w.Code)
+	}
+
+	if len(db.tokens.revoked) != 0 || len(consents.deleted) != 0 {
+		t.Fatalf("dashboard tokens or consent were revoked")
+	}
+}
@@ -3,6 +3,8 @@ package authapi
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/common"
+	"strings"
 )
 
 // APIController is an interface for retrieving gin middleware for
@@ -10,38 +12,111 @@ import (
 type APIController interface {
 	SignUpRoute() gin.HandlerFunc
 	SignInRoute() gin.HandlerFunc
+	SignOutRoute() gin.HandlerFunc
+	LogoutAllRoute() gin.HandlerFunc
 	VerifyEmailRoute() gin.HandlerFunc
 
 	AuthorizationRoute() gin.HandlerFunc
+	PushedAuthorizationRoute() gin.HandlerFunc
+	GetPushedRequestRoute() gin.HandlerFunc
 	TokenRoute() gin.HandlerFunc
+	IntrospectionRoute() gin.HandlerFunc
+	RevocationRoute() gin.HandlerFunc
+	DeviceAuthorizationRoute() gin.HandlerFunc
+	DeviceVerificationRoute() gin.HandlerFunc
+
+	AuthorizationServerMetadataRoute() gin.HandlerFunc
+	OpenIDConfigurationRoute() gin.HandlerFunc
+	JWKSRoute() gin.HandlerFunc
+	UserInfoRoute() gin.HandlerFunc
 
 	GetUserRoute() gin.HandlerFunc
 	UpdateUserRoute() gin.HandlerFunc
 	UpdateUserRealmsRoute() gin.HandlerFunc
 	DeleteUserRoute() gin.HandlerFunc
+	LogoutUserRoute() gin.HandlerFunc
 	GetUsersRoute() gin.HandlerFunc
 	ResetPwdRoute() gin.HandlerFunc
+	GetUserAppsRoute() gin.HandlerFunc
+	RevokeUserAppRoute() gin.HandlerFunc
 
 	GetClientRoute() gin.HandlerFunc
 	CreateClientRoute() gin.HandlerFunc
+	RotateClientSecretRoute() gin.HandlerFunc
 	DeleteClientRoute() gin.HandlerFunc
 	GetClientsRoute() gin.HandlerFunc
 
+	CreateResourceRoute() gin.HandlerFunc
+	GetResourcesRoute() gin.HandlerFunc
+	DeleteResourceRoute() gin.HandlerFunc
+
+	CreateInitialTokenRoute() gin.HandlerFunc
+	RegisterClientRoute() gin.HandlerFunc
+	GetRegistrationRoute() gin.HandlerFunc
+	UpdateRegistrationRoute() gin.HandlerFunc
+	DeleteRegistrationRoute() gin.HandlerFunc
+
 	DB() authdb.Database
 	Stop() error
 }
 
+// Config defines the authorization server's public identity.
+type Config struct {
+	// Issuer is the externally reachable base URL of the API, used as
+	// the OpenID Connect issuer identifier.
+	Issuer string
+
+	// Frontend is the base URL of the dashboard, which hosts the
+	// user-facing authorization page.
+	Frontend string
+
+	// SigningKey signs ID tokens and JWT access tokens. A random key is
+	// generated if nil.
+	SigningKey *common.SigningKey
+
+	// AccessTokenFormat is either TokenFormatOpaque (default) or
+	// TokenFormatJWT.
+	AccessTokenFormat string
+
+	// DPoPNonceKey derives the nonces that DPoP proofs sent to the
+	// token route must carry. Nonces are not required if empty.
+	DPoPNonceKey []byte
+
+	// ClientCertificates reports whether client certificates reach the
+	// server, over direct TLS connections or forwarded by a proxy.
+	// Clients cannot register certificate authentication otherwise.
+	ClientCertificates bool
+
+	// LegacyTokenGET accepts token requests sent as GET query strings,
+	// as the token route did before it accepted form-encoded POST
+	// requests. Query strings leak secrets into access logs, so this is
+	// only meant for clients that have not migrated yet.
+	LegacyTokenGET bool
+}
+
 // DefaultAPIController implements APIController using authdb.
 type DefaultAPIController struct {
 	db      authdb.Database
 	address string
 	websmtp string
+	config  Config
 }
 
 // CreateAPIController creates an instance of APIController using uri and
 // name as the MongoDB connection string and database name, respectively.
 // addr is the email address to send verification emails from.
-func CreateAPIController(uri, name, addr, websmtp string) (APIController, error) {
+func CreateAPIController(uri, name, addr, websmtp string,
+	config Config) (APIController, error) {
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	config.Frontend = strings.TrimSuffix(config.Frontend, "/")
+	if config.SigningKey == nil {
+		key, err := common.GenerateSigningKey()
+		if err != nil {
+			return nil, err
+		}
+		config.SigningKey = key
+	}
+
 	cntrl := new(DefaultAPIController)
 	db, err := authdb.NewDatabase(uri, name)
 	if err != nil {
@@ -50,6 +125,7 @@ func CreateAPIController(uri, name, addr, websmtp string) (APIController, error)
 	cntrl.db = db
 	cntrl.address = addr
 	cntrl.websmtp = websmtp
+	cntrl.config = config
 	return cntrl, nil
 }
 
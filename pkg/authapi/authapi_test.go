@@ -0,0 +1,188 @@
+package authapi
+
+import (
+	"errors"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// errNotFound is returned by the test database for missing documents.
+var errNotFound = errors.New("document not found")
+
+// testDB is an in-memory authdb.Database. Controllers embed their
+// interface, so calling an operation that a test does not expect panics.
+type testDB struct {
+	users     *testUsers
+	tokens    *testTokens
+	clients   *testClients
+	consents  authdb.ConsentController
+	resources *testResources
+}
+
+func newTestDB() *testDB {
+	return &testDB{
+		users: &testUsers{users: map[string]authdb.UserModel{}},
+		tokens: &testTokens{
+			codes:   map[string]authdb.TokenModel{},
+			access:  map[string]authdb.TokenModel{},
+			devices: map[string]authdb.DeviceModel{},
+			pushed:  map[string]authdb.PushedRequestModel{},
+			initial: map[string]authdb.TokenModel{},
+		},
+		clients:   &testClients{clients: map[string]authdb.ClientModel{}},
+		resources: &testResources{resources: map[string]authdb.ResourceModel{}},
+	}
+}
+
+func (db *testDB) Users() authdb.UserController         { return db.users }
+func (db *testDB) Tokens() authdb.TokenController       { return db.tokens }
+func (db *testDB) Clients() authdb.ClientController     { return db.clients }
+func (db *testDB) Consents() authdb.ConsentController   { return db.consents }
+func (db *testDB) Resources() authdb.ResourceController { return db.resources }
+
+type testUsers struct {
+	authdb.UserController
+	users map[string]authdb.UserModel
+}
+
+func (u *testUsers) FindByID(id string) (authdb.UserModel, error) {
+	if user, ok := u.users[id]; ok {
+		return user, nil
+	}
+	return authdb.UserModel{}, errNotFound
+}
+
+type testClients struct {
+	authdb.ClientController
+	clients map[string]authdb.ClientModel
+}
+
+func (cc *testClients) FindByID(id string) (authdb.ClientModel, error) {
+	if client, ok := cc.clients[id]; ok {
+		return client, nil
+	}
+	return authdb.ClientModel{}, errNotFound
+}
+
+func (cc *testClients) FindByName(name string) (authdb.ClientModel, error) {
+	for _, client := range cc.clients {
+		if client.Name == name {
+			return client, nil
+		}
+	}
+	return authdb.ClientModel{}, mongo.ErrNoDocuments
+}
+
+func (cc *testClients) Create(client authdb.ClientModel) (string, error) {
+	client.ID = client.Name
+	cc.clients[client.ID] = client
+	return client.ID, nil
+}
+
+func (cc *testClients) Update(client authdb.ClientModel) (int64, error) {
+	cc.clients[client.ID] = client
+	return 1, nil
+}
+
+func (cc *testClients) FindByIDs(ids []string) ([]authdb.ClientModel, error) {
+	clients := []authdb.ClientModel{}
+	for _, id := range ids {
+		if client, ok := cc.clients[id]; ok {
+			clients = append(clients, client)
+		}
+	}
+	return clients, nil
+}
+
+type testResources struct {
+	authdb.ResourceController
+	resources map[string]authdb.ResourceModel
+}
+
+func (rc *testResources) FindByAudience(aud string) (authdb.ResourceModel, error) {
+	if resource, ok := rc.resources[aud]; ok {
+		return resource, nil
+	}
+	return authdb.ResourceModel{}, errNotFound
+}
+
+type testTokens struct {
+	authdb.TokenController
+	codes   map[string]authdb.TokenModel
+	access  map[string]authdb.TokenModel
+	devices map[string]authdb.DeviceModel
+	pushed  map[string]authdb.PushedRequestModel
+	initial map[string]authdb.TokenModel
+	revoked []string
+}
+
+func (tc *testTokens) ConsumeAuth(id string) (authdb.TokenModel, error) {
+	code, ok := tc.codes[id]
+	if !ok {
+		return authdb.TokenModel{}, errNotFound
+	}
+	delete(tc.codes, id)
+	return code, nil
+}
+
+func (tc *testTokens) DeleteByCode(string) error { return nil }
+
+func (tc *testTokens) DeleteByUserClient(userID, clientID string) error {
+	tc.revoked = append(tc.revoked, userID+":"+clientID)
+	return nil
+}
+
+func (tc *testTokens) CreateAccess(token authdb.TokenModel) (string, error) {
+	tc.access[token.ID] = token
+	return token.ID, nil
+}
+
+func (tc *testTokens) FindByUser(userID string, now int64) ([]authdb.TokenModel, error) {
+	tokens := []authdb.TokenModel{}
+	for _, token := range tc.access {
+		if token.UserID == userID && token.CreatedAt+token.TTL > now {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+func (tc *testTokens) FindAccessByID(id string) (authdb.TokenModel, error) {
+	if token, ok := tc.access[id]; ok {
+		return token, nil
+	}
+	return authdb.TokenModel{}, errNotFound
+}
+
+func (tc *testTokens) CreateDevice(device authdb.DeviceModel) (string, error) {
+	tc.devices[device.ID] = device
+	return device.ID, nil
+}
+
+func (tc *testTokens) DeleteAccessByID(id string) error {
+	delete(tc.access, id)
+	return nil
+}
+
+func (tc *testTokens) FindPushedByID(id string) (authdb.PushedRequestModel, error) {
+	if req, ok := tc.pushed[id]; ok {
+		return req, nil
+	}
+	return authdb.PushedRequestModel{}, errNotFound
+}
+
+func (tc *testTokens) FindInitialByID(id string) (authdb.TokenModel, error) {
+	if token, ok := tc.initial[id]; ok {
+		return token, nil
+	}
+	return authdb.TokenModel{}, errNotFound
+}
+
+func (tc *testTokens) ConsumeInitial(id string) (authdb.TokenModel, error) {
+	token, ok := tc.initial[id]
+	if !ok {
+		return authdb.TokenModel{}, errNotFound
+	}
+	delete(tc.initial, id)
+	return token, nil
+}
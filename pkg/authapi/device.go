@@ -0,0 +1,363 @@
+package authapi
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/authmw"
+	"github.com/ufosc/OpenWebServices/pkg/common"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// deviceCodeGrant is the device authorization grant type.
+// See: https://datatracker.ietf.org/doc/html/rfc8628#section-3.4
+const deviceCodeGrant = "urn:ietf:params:oauth:grant-type:device_code"
+
+// deviceClient returns the client making a device flow request. Devices
+// are usually unable to keep a secret, so clients registered as public
+// may omit credentials; all other clients must authenticate.
+func (cntrl *DefaultAPIController) deviceClient(c *gin.Context, id string) (
+	authdb.ClientModel, bool) {
+	if !authmw.HasCredentials(c) {
+		return cntrl.publicClient(c, id)
+	}
+
+	authmw.C(cntrl.db, cntrl.config.Issuer)(c)
+	if c.IsAborted() {
+		return authdb.ClientModel{}, false
+	}
+
+	clientAny, _ := c.Get("client")
+	client, ok := clientAny.(authdb.ClientModel)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":             "not_found",
+			"error_description": "Client not found",
+		})
+		return authdb.ClientModel{}, false
+	}
+
+	return client, true
+}
+
+// normalizeUserCode removes formatting from a user-entered user code.
+func normalizeUserCode(code string) string {
+	code = strings.ToUpper(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+// DeviceAuthorizationRoute issues a device code and user code pair to a
+// device that cannot open a browser.
+// See: https://datatracker.ietf.org/doc/html/rfc8628#section-3.1
+func (cntrl *DefaultAPIController) DeviceAuthorizationRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		client, ok := cntrl.deviceClient(c, c.PostForm("client_id"))
+		if !ok {
+			return
+		}
+
+		// Downscope the request to the client's registered scope.
+		scope := grantScope(client, strings.Fields(c.PostForm("scope")))
+		if len(scope) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_scope",
+				"error_description": "none of the requested scope is registered by this client",
+			})
+			return
+		}
+
+		userCode, err := common.UserCode()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "Internal server error. Please try again later",
+			})
+			return
+		}
+
+		device := authdb.DeviceModel{
+			ID:         common.UUID(),
+			UserCode:   userCode,
+			ClientID:   client.ID,
+			UserID:     "",
+			Status:     authdb.DevicePending,
+			Interval:   5,
+			LastPolled: 0,
+			Scope:      scope,
+			CreatedAt:  time.Now().Unix(),
+			TTL:        600,
+		}
+
+		id, err := cntrl.db.Tokens().CreateDevice(device)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "Internal server error. Please try again later",
+			})
+			return
+		}
+
+		display := userCode[:4] + "-" + userCode[4:]
+		verificationURI := cntrl.config.Frontend + "/device"
+		c.JSON(http.StatusOK, gin.H{
+			"device_code":               id,
+			"user_code":                 display,
+			"verification_uri":          verificationURI,
+			"verification_uri_complete": verificationURI + "?user_code=" + display,
+			"expires_in":                device.TTL,
+			"interval":                  device.Interval,
+		})
+	}
+}
+
+// DeviceVerificationRoute lets a signed-in user approve or deny the device
+// authorization request identified by a user code.
+func (cntrl *DefaultAPIController) DeviceVerificationRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		// Get underlying user.
+		userAny, _ := c.Get("user")
+		user, _ := userAny.(authdb.UserModel)
+
+		action := c.DefaultQuery("action", "")
+		if action != "approve" && action != "deny" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_request",
+				"error_description": "action must be 'approve' or 'deny'",
+			})
+			return
+		}
+
+		userCode := normalizeUserCode(c.DefaultQuery("user_code", ""))
+		device, err := cntrl.db.Tokens().FindDeviceByUserCode(userCode)
+		if err != nil || (device.CreatedAt+device.TTL) < time.Now().Unix() {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":             "not_found",
+				"error_description": "user code expired or could not be found",
+			})
+			return
+		}
+
+		if device.Status != authdb.DevicePending {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_request",
+				"error_description": "user code has already been used",
+			})
+			return
+		}
+
+		client, err := cntrl.db.Clients().FindByID(device.ClientID)
+		if err != nil {
+			cntrl.db.Tokens().DeleteDeviceByID(device.ID)
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":             "not_found",
+				"error_description": "client not found",
+			})
+			return
+		}
+
+		device.Status = authdb.DeviceDenied
+		if action == "approve" {
+			device.Status = authdb.DeviceApproved
+			device.UserID = user.ID
+
+			// Record that the user consented to the requested scope.
+			err := cntrl.db.Consents().Grant(user.ID, client.ID, device.Scope)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":             "internal_server_error",
+					"error_description": "Internal server error. Please try again later",
+				})
+				return
+			}
+		}
+
+		if err := cntrl.db.Tokens().UpdateDevice(device); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "Internal server error. Please try again later",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "success",
+			"status":    device.Status,
+			"client_id": client.ID,
+			"name":      client.Name,
+			"scope":     device.Scope,
+		})
+	}
+}
+
+func (cntrl *DefaultAPIController) handleDeviceCode(c *gin.Context) {
+	public := !authmw.HasCredentials(c)
+	client, ok := cntrl.deviceClient(c, tokenParam(c, "client_id"))
+	if !ok {
+		return
+	}
+
+	deviceCode := tokenParam(c, "device_code")
+	if deviceCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_request",
+			"error_description": "missing device_code parameter",
+		})
+		return
+	}
+
+	device, err := cntrl.db.Tokens().FindDeviceByID(deviceCode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "expired_token",
+			"error_description": "device code expired or could not be found",
+		})
+		return
+	}
+
+	// Ensure device code has not expired.
+	now := time.Now().Unix()
+	if (device.CreatedAt + device.TTL) < now {
+		cntrl.db.Tokens().DeleteDeviceByID(device.ID)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "expired_token",
+			"error_description": "device code expired or could not be found",
+		})
+		return
+	}
+
+	// Ensure device code was issued to this client.
+	if device.ClientID != client.ID {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_grant",
+			"error_description": "device code was not issued to this client",
+		})
+		return
+	}
+
+	// See: https://datatracker.ietf.org/doc/html/rfc8628#section-3.5
+	switch device.Status {
+	case authdb.DevicePending:
+		errCode := "authorization_pending"
+		if now-device.LastPolled < device.Interval {
+			errCode = "slow_down"
+			device.Interval += 5
+		}
+
+		device.LastPolled = now
+		cntrl.db.Tokens().UpdateDevice(device)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             errCode,
+			"error_description": "the user has not yet approved this request",
+		})
+		return
+	case authdb.DeviceDenied:
+		cntrl.db.Tokens().DeleteDeviceByID(device.ID)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "access_denied",
+			"error_description": "the user denied this request",
+		})
+		return
+	}
+
+	audience, ok := cntrl.targetAudience(splitResources(tokenParams(c, "resource")), nil)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_target",
+			"error_description": "resource is not a registered resource server",
+		})
+		return
+	}
+
+	// Device code is single use. Only the request that consumes it is
+	// issued tokens.
+	device, err = cntrl.db.Tokens().ConsumeDevice(device.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_grant",
+			"error_description": "device code has already been used",
+		})
+		return
+	}
+
+	// Ensure user still exists.
+	if _, err := cntrl.db.Users().FindByID(device.UserID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_grant",
+			"error_description": "the user associated with this request could not be found",
+		})
+		return
+	}
+
+	// Public clients cannot authenticate at the refresh token grant,
+	// so they are only issued an access token.
+	refreshID := ""
+	if !public {
+		refreshID = common.UUID()
+	}
+
+	// Create access token.
+	atoken := authdb.TokenModel{
+		ID:        common.UUID(),
+		ClientID:  client.ID,
+		UserID:    device.UserID,
+		CreatedAt: now,
+		TTL:       1200,
+		Scope:     device.Scope,
+		Audience:  audience,
+		RefreshID: refreshID,
+		FamilyID:  refreshID,
+		JKT:       dpopJKT(c),
+		X5T:       authmw.CertificateThumbprint(c),
+	}
+
+	aid, err := cntrl.createAccess(atoken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":             "internal_server_error",
+			"error_description": "Internal server error. Please try again later",
+		})
+		return
+	}
+
+	res := gin.H{
+		"message":      "success",
+		"access_token": aid,
+		"token_type":   tokenType(c),
+		"expires_in":   1200,
+		"scope":        strings.Join(device.Scope, " "),
+	}
+
+	if public {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	// Create refresh token.
+	rtoken := authdb.TokenModel{
+		ID:        refreshID,
+		ClientID:  client.ID,
+		UserID:    device.UserID,
+		CreatedAt: now,
+		TTL:       5256000,
+		Scope:     device.Scope,
+		Audience:  audience,
+		FamilyID:  refreshID,
+	}
+
+	rid, err := cntrl.db.Tokens().CreateRefresh(rtoken)
+	if err != nil {
+		cntrl.db.Tokens().DeleteAccessByID(atoken.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":             "internal_server_error",
+			"error_description": "Internal server error. Please try again later",
+		})
+		return
+	}
+
+	res["refresh_token"] = rid
+	c.JSON(http.StatusOK, res)
+}
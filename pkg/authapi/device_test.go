@@ -0,0 +1,109 @@
+package authapi
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/authmw"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// genDeviceDB returns a database holding a public client "device" and a
+// confidential client "confidential", and a device code "approved" that
+// the user approved for the confidential client.
+func genDeviceDB() *testDB {
+	now := time.Now().Unix()
+	db := newTestDB()
+	db.clients.clients["device"] = authdb.ClientModel{
+		ID:         "device",
+		Scope:      []string{"public"},
+		AuthMethod: authmw.PublicClient,
+		CreatedAt:  now,
+		TTL:        3600,
+	}
+	db.clients.clients["confidential"] = authdb.ClientModel{
+		ID:        "confidential",
+		Scope:     []string{"public"},
+		CreatedAt: now,
+		TTL:       3600,
+	}
+	db.tokens.devices["approved"] = authdb.DeviceModel{
+		ID:        "approved",
+		ClientID:  "confidential",
+		UserID:    "user",
+		Status:    authdb.DeviceApproved,
+		Scope:     []string{"public"},
+		CreatedAt: now,
+		TTL:       600,
+	}
+	return db
+}
+
+// postDevice sends form to handler without client credentials and returns
+// the response status and error code.
+func postDevice(handler gin.HandlerFunc, form url.Values) (int, string) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/auth/token",
+		strings.NewReader(form.Encode()))
+	c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler(c)
+
+	var res struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	return w.Code, res.Error
+}
+
+func TestDeviceAuthorizationPublic(t *testing.T) {
+	db := genDeviceDB()
+	cntrl := &DefaultAPIController{db: db}
+	form := url.Values{"client_id": {"device"}, "scope": {"public"}}
+	if code, err := postDevice(cntrl.DeviceAuthorizationRoute(), form); code != http.StatusOK {
+		t.Fatalf("public client denied device authorization: %d %s", code, err)
+	}
+
+	if len(db.tokens.devices) != 2 {
+		t.Fatalf("device code not created")
+	}
+}
+
+func TestDeviceAuthorizationConfidentialWithoutSecret(t *testing.T) {
+	db := genDeviceDB()
+	cntrl := &DefaultAPIController{db: db}
+	form := url.Values{"client_id": {"confidential"}, "scope": {"public"}}
+	code, err := postDevice(cntrl.DeviceAuthorizationRoute(), form)
+	if code != http.StatusUnauthorized || err != "invalid_client" {
+		t.Fatalf("expected 401 invalid_client, got %d %s", code, err)
+	}
+
+	if len(db.tokens.devices) != 1 {
+		t.Fatalf("device code created without client authentication")
+	}
+}
+
+func TestHandleDeviceCodeConfidentialWithoutSecret(t *testing.T) {
+	db := genDeviceDB()
+	cntrl := &DefaultAPIController{db: db}
+	form := url.Values{
+		"grant_type":  {deviceCodeGrant},
+		"client_id":   {"confidential"},
+		"device_code": {"approved"},
+	}
+
+	code, err := postDevice(cntrl.handleDeviceCode, form)
+	if code != http.StatusUnauthorized || err != "invalid_client" {
+		t.Fatalf("expected 401 invalid_client, got %d %s", code, err)
+	}
+
+	if len(db.tokens.access) != 0 {
+		t.Fatalf("issued an access token without client authentication")
+	}
+}
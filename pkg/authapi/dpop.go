@@ -0,0 +1,76 @@
+package authapi
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/common"
+	"net/http"
+	"time"
+)
+
+// dpopKey verifies the DPoP proof sent to the token route, if any, and
+// returns the JWK thumbprint of the key that issued access tokens are
+// bound to. It writes an error response and returns false if the proof
+// is invalid, lacks the current nonce or was used before.
+// See: https://datatracker.ietf.org/doc/html/rfc9449#section-5
+func (cntrl *DefaultAPIController) dpopKey(c *gin.Context) (string, bool) {
+	proofs := c.Request.Header.Values("DPoP")
+	if len(proofs) == 0 {
+		return "", true
+	}
+
+	now := time.Now().Unix()
+	claims, jkt, err := common.VerifyDPoP(proofs[0], c.Request.Method,
+		cntrl.config.Issuer+c.Request.URL.Path, now)
+
+	if err != nil || len(proofs) > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_dpop_proof",
+			"error_description": "expected a single valid DPoP proof",
+		})
+		return "", false
+	}
+
+	key := cntrl.config.DPoPNonceKey
+	if len(key) > 0 && !common.VerifyDPoPNonce(key, claims.Nonce, now) {
+		c.Header("DPoP-Nonce", common.NewDPoPNonce(key, now))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "use_dpop_nonce",
+			"error_description": "Authorization server requires nonce in DPoP proof",
+		})
+		return "", false
+	}
+
+	// Proofs are remembered for as long as their "iat" is accepted.
+	used, err := cntrl.db.Tokens().UseDPoPProof(authdb.DPoPProofModel{
+		ID:        jkt + ":" + claims.ID,
+		JKT:       jkt,
+		CreatedAt: now,
+		TTL:       2 * common.DPoPMaxAge,
+	})
+
+	if err != nil || !used {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_dpop_proof",
+			"error_description": "DPoP proof has already been used",
+		})
+		return "", false
+	}
+
+	return jkt, true
+}
+
+// dpopJKT returns the DPoP key that tokens issued by the current token
+// request are bound to, or an empty string for bearer tokens.
+func dpopJKT(c *gin.Context) string {
+	return c.GetString("dpop_jkt")
+}
+
+// tokenType returns the "token_type" of access tokens issued by the
+// current token request.
+func tokenType(c *gin.Context) string {
+	if dpopJKT(c) != "" {
+		return "DPoP"
+	}
+	return "bearer"
+}
@@ -0,0 +1,202 @@
+package authapi
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/authmw"
+	"github.com/ufosc/OpenWebServices/pkg/common"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Token exchange grant and token type identifiers.
+// See: https://datatracker.ietf.org/doc/html/rfc8693#section-3
+const (
+	tokenExchangeGrant = "urn:ietf:params:oauth:grant-type:token-exchange"
+	accessTokenType    = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+// handleTokenExchange lets a confidential client swap an access token it
+// received for a narrower token to call another client's API on behalf of
+// the same user. The authenticated client is recorded as the actor.
+func (cntrl *DefaultAPIController) handleTokenExchange(c *gin.Context) {
+	authmw.C(cntrl.db, cntrl.config.Issuer)(c)
+	if c.IsAborted() {
+		return
+	}
+
+	clientAny, _ := c.Get("client")
+	client, ok := clientAny.(authdb.ClientModel)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":             "not_found",
+			"error_description": "Client not found",
+		})
+		return
+	}
+
+	subjectToken := tokenParam(c, "subject_token")
+	subjectType := tokenParam(c, "subject_token_type")
+	requestedType := tokenParam(c, "requested_token_type")
+	if requestedType == "" {
+		requestedType = accessTokenType
+	}
+	if subjectToken == "" || subjectType != accessTokenType ||
+		requestedType != accessTokenType {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_request",
+			"error_description": "subject_token must be an access token and only access tokens can be requested",
+		})
+		return
+	}
+
+	// Actors are always the authenticated client.
+	if tokenParam(c, "actor_token") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_request",
+			"error_description": "actor_token is not supported",
+		})
+		return
+	}
+
+	// Verify subject token.
+	now := time.Now().Unix()
+	subject, err := cntrl.db.Tokens().FindAccessByID(cntrl.accessTokenID(subjectToken))
+	if err != nil || (subject.CreatedAt+subject.TTL) < now || subject.UserID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_grant",
+			"error_description": "subject_token is expired, invalid or not issued to a user",
+		})
+		return
+	}
+
+	// Delegated tokens can only be exchanged by their audience.
+	if len(subject.Audience) > 0 && !cntrl.inAudience(client, subject.Audience) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_grant",
+			"error_description": "subject_token was not issued to this client",
+		})
+		return
+	}
+
+	// DPoP-bound tokens can only be exchanged with a proof of the
+	// same key.
+	if subject.JKT != "" && subject.JKT != dpopJKT(c) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_grant",
+			"error_description": "subject_token is bound to another DPoP key",
+		})
+		return
+	}
+
+	// Certificate-bound tokens can only be exchanged by a client that
+	// authenticated with the same certificate.
+	if subject.X5T != "" && subject.X5T != authmw.CertificateThumbprint(c) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_grant",
+			"error_description": "subject_token is bound to another client certificate",
+		})
+		return
+	}
+
+	if _, err := cntrl.db.Users().FindByID(subject.UserID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_grant",
+			"error_description": "the user associated with this token could not be found",
+		})
+		return
+	}
+
+	// The exchanged token is limited to the scope of the subject token
+	// and the scope registered by the acting client.
+	subjectScope, err := cntrl.tokenScope(subject)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_grant",
+			"error_description": "the client associated with this token could not be found",
+		})
+		return
+	}
+
+	scope := []string{}
+	for _, value := range grantScope(client, strings.Fields(tokenParam(c, "scope"))) {
+		if hasScope(subjectScope, value) {
+			scope = append(scope, value)
+		}
+	}
+
+	if len(scope) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_scope",
+			"error_description": "none of the requested scope is granted to both the subject token and this client",
+		})
+		return
+	}
+
+	// Audiences are the clients and resource servers that the token will
+	// be presented to.
+	audience := tokenParams(c, "audience")
+	for _, aud := range audience {
+		if _, err := cntrl.db.Clients().FindByID(aud); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_target",
+				"error_description": "audience " + aud + " is not a registered client",
+			})
+			return
+		}
+	}
+
+	resources, ok := cntrl.targetAudience(splitResources(tokenParams(c, "resource")), nil)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_target",
+			"error_description": "resource is not a registered resource server",
+		})
+		return
+	}
+
+	audience = append(audience, resources...)
+	if len(audience) == 0 {
+		audience = []string{client.ID}
+	}
+
+	// The exchanged token cannot outlive the subject token.
+	ttl := subject.CreatedAt + subject.TTL - now
+	if ttl > 1200 {
+		ttl = 1200
+	}
+
+	atoken := authdb.TokenModel{
+		ID:        common.UUID(),
+		ClientID:  client.ID,
+		UserID:    subject.UserID,
+		CreatedAt: now,
+		TTL:       ttl,
+		Scope:     scope,
+		Audience:  audience,
+		Actors:    append([]string{client.ID}, subject.Actors...),
+		FamilyID:  subject.FamilyID,
+		JKT:       dpopJKT(c),
+		X5T:       authmw.CertificateThumbprint(c),
+		CodeID:    subject.CodeID,
+	}
+
+	aid, err := cntrl.createAccess(atoken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":             "internal_server_error",
+			"error_description": "Internal server error. Please try again later",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "success",
+		"access_token":      aid,
+		"issued_token_type": accessTokenType,
+		"token_type":        tokenType(c),
+		"expires_in":        ttl,
+		"scope":             strings.Join(scope, " "),
+	})
+}
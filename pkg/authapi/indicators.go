@@ -0,0 +1,267 @@
+package authapi
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// validResourceURI reports whether uri can identify a resource server: an
+// absolute URI without a fragment.
+// See: https://datatracker.ietf.org/doc/html/rfc8707#section-2
+func validResourceURI(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && u.IsAbs() && u.Host != "" && u.Fragment == "" &&
+		!strings.Contains(uri, "#")
+}
+
+// splitResources splits resource parameter values. Resource indicators
+// cannot contain spaces, so multiple indicators may also be sent as a
+// single space-separated value.
+func splitResources(values []string) []string {
+	resources := []string{}
+	for _, value := range values {
+		resources = append(resources, strings.Fields(value)...)
+	}
+	return resources
+}
+
+// isResource reports whether uri identifies this server or a registered
+// resource server.
+func (cntrl *DefaultAPIController) isResource(uri string) bool {
+	if uri == cntrl.config.Issuer {
+		return true
+	}
+	_, err := cntrl.db.Resources().FindByAudience(uri)
+	return err == nil
+}
+
+// targetAudience returns the audience of a token requested for resources.
+// Tokens derived from an earlier grant can only be narrowed to resources
+// in its audience (allowed). If no resource is requested, the token keeps
+// the allowed audience, which is empty for unrestricted tokens. Returns
+// false if a requested resource is unknown or not allowed.
+func (cntrl *DefaultAPIController) targetAudience(requested,
+	allowed []string) ([]string, bool) {
+	if len(requested) == 0 {
+		return allowed, true
+	}
+
+	audience := []string{}
+	for _, resource := range requested {
+		if hasScope(audience, resource) {
+			continue
+		}
+
+		if len(allowed) > 0 && !hasScope(allowed, resource) {
+			return nil, false
+		}
+
+		if len(allowed) == 0 && !cntrl.isResource(resource) {
+			return nil, false
+		}
+
+		audience = append(audience, resource)
+	}
+
+	return audience, true
+}
+
+// inAudience reports whether client is an audience of a token, either by
+// its ID or as the client of a resource server in the audience.
+func (cntrl *DefaultAPIController) inAudience(client authdb.ClientModel,
+	audience []string) bool {
+	if hasScope(audience, client.ID) {
+		return true
+	}
+
+	for _, aud := range audience {
+		resource, err := cntrl.db.Resources().FindByAudience(aud)
+		if err == nil && resource.ClientID == client.ID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CreateResourceRoute registers a resource server that clients can request
+// audience restricted tokens for.
+func (cntrl *DefaultAPIController) CreateResourceRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Name     string `json:"name" binding:"required"`
+			Audience string `json:"audience" binding:"required"`
+			ClientID string `json:"client_id"`
+		}
+
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_request",
+				"error_description": "Missing required fields",
+			})
+			return
+		}
+
+		userAny, _ := c.Get("user")
+		user, _ := userAny.(authdb.UserModel)
+
+		if !validResourceURI(req.Audience) || req.Audience == cntrl.config.Issuer {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_request",
+				"error_description": "audience must be an absolute URI without a fragment",
+			})
+			return
+		}
+
+		if _, err := cntrl.db.Resources().FindByAudience(req.Audience); err == nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_request",
+				"error_description": "audience is already registered",
+			})
+			return
+		}
+
+		// Resource servers can only act as clients that the user owns.
+		if req.ClientID != "" {
+			client, err := cntrl.db.Clients().FindByID(req.ClientID)
+			if err != nil || client.Owner != user.ID {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":             "invalid_request",
+					"error_description": "client_id does not belong to you",
+				})
+				return
+			}
+		}
+
+		id, err := cntrl.db.Resources().Create(authdb.ResourceModel{
+			Name:      req.Name,
+			Audience:  req.Audience,
+			ClientID:  req.ClientID,
+			Owner:     user.ID,
+			CreatedAt: time.Now().Unix(),
+		})
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "Internal server error. Please try again later",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "success",
+			"id":       id,
+			"audience": req.Audience,
+		})
+	}
+}
+
+// GetResourcesRoute returns the batch of 10 resource servers determined by
+// the page URL parameter.
+func (cntrl *DefaultAPIController) GetResourcesRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pagei, err := strconv.ParseInt(c.DefaultQuery("page", "0"), 10, 64)
+		if err != nil || pagei < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_request",
+				"error_description": "page must be >= 0",
+			})
+			return
+		}
+
+		docs, err := cntrl.db.Resources().Batch(10, pagei*10)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "failed to fetch documents from server",
+			})
+			return
+		}
+
+		count, err := cntrl.db.Resources().Count()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "failed to fetch documents from server",
+			})
+			return
+		}
+
+		type resourcePublic struct {
+			ID        string `json:"id"`
+			Name      string `json:"name"`
+			Audience  string `json:"audience"`
+			ClientID  string `json:"client_id,omitempty"`
+			Owner     string `json:"owner"`
+			CreatedAt int64  `json:"created_at"`
+		}
+
+		resources := []resourcePublic{}
+		for _, doc := range docs {
+			resources = append(resources, resourcePublic{
+				doc.ID, doc.Name, doc.Audience,
+				doc.ClientID, doc.Owner, doc.CreatedAt,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "success",
+			"count":       len(resources),
+			"total_count": count,
+			"resources":   resources,
+		})
+	}
+}
+
+// DeleteResourceRoute deletes a resource server. Tokens already restricted
+// to it remain valid until they expire.
+func (cntrl *DefaultAPIController) DeleteResourceRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userAny, _ := c.Get("user")
+		user, _ := userAny.(authdb.UserModel)
+
+		resource, err := cntrl.db.Resources().FindByID(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":             "not_found",
+				"error_description": "resource not found",
+			})
+			return
+		}
+
+		// Whether the user can delete any resource server.
+		hasDeletionRealm := false
+		for _, realm := range user.Realms {
+			if realm == "clients.delete" {
+				hasDeletionRealm = true
+				break
+			}
+		}
+
+		if resource.Owner != user.ID && !hasDeletionRealm {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":             "unauthorized",
+				"error_description": "resource does not belong to you",
+			})
+			return
+		}
+
+		if err := cntrl.db.Resources().DeleteByID(resource.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "could not delete resource at this time, please try again later",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "resource deleted successfully",
+		})
+	}
+}
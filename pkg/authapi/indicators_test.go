@@ -0,0 +1,66 @@
+package authapi
+
+import (
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"reflect"
+	"testing"
+)
+
+const (
+	testIssuer   = "https://auth.example.com"
+	testResource = "https://api.example.com"
+)
+
+// genResourceController returns a controller that knows testResource.
+func genResourceController() *DefaultAPIController {
+	db := newTestDB()
+	db.resources.resources[testResource] = authdb.ResourceModel{Audience: testResource}
+	return &DefaultAPIController{db: db, config: Config{Issuer: testIssuer}}
+}
+
+func TestTargetAudience(t *testing.T) {
+	cntrl := genResourceController()
+	if got, ok := cntrl.targetAudience(nil, nil); !ok || len(got) != 0 {
+		t.Errorf("audience restricted without resources, got %v", got)
+	}
+
+	got, ok := cntrl.targetAudience([]string{testResource}, nil)
+	if !ok || !reflect.DeepEqual(got, []string{testResource}) {
+		t.Errorf("registered resource not targeted, got %v", got)
+	}
+
+	got, ok = cntrl.targetAudience([]string{testIssuer}, nil)
+	if !ok || !reflect.DeepEqual(got, []string{testIssuer}) {
+		t.Errorf("issuer not targeted, got %v", got)
+	}
+
+	got, ok = cntrl.targetAudience([]string{testResource, testResource}, nil)
+	if !ok || !reflect.DeepEqual(got, []string{testResource}) {
+		t.Errorf("duplicate resource not dropped, got %v", got)
+	}
+}
+
+func TestTargetAudienceAllowed(t *testing.T) {
+	// Refreshed and exchanged tokens keep or narrow their audience.
+	cntrl := genResourceController()
+	got, ok := cntrl.targetAudience(nil, []string{testResource})
+	if !ok || !reflect.DeepEqual(got, []string{testResource}) {
+		t.Errorf("allowed audience not kept, got %v", got)
+	}
+
+	got, ok = cntrl.targetAudience([]string{testResource}, []string{testResource, testIssuer})
+	if !ok || !reflect.DeepEqual(got, []string{testResource}) {
+		t.Errorf("audience not narrowed, got %v", got)
+	}
+
+	if _, ok := cntrl.targetAudience([]string{testIssuer}, []string{testResource}); ok {
+		t.Errorf("audience widened beyond the allowed audience")
+	}
+}
+
+func TestTargetAudienceUnknownResource(t *testing.T) {
+	cntrl := genResourceController()
+	if _, ok := cntrl.targetAudience([]string{"https://evil.example.com"}, nil); ok {
+		t.Fatalf("unregistered resource targeted")
+	}
+}
@@ -0,0 +1,165 @@
+package authapi
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/authmw"
+	"github.com/ufosc/OpenWebServices/pkg/common"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// introspectionScope is registered by clients, such as resource servers
+// that are not an audience of the tokens they receive, that may introspect
+// any token. It is never granted to tokens.
+const introspectionScope = "introspection"
+
+// findToken looks up an access or refresh token by its ID. The hint
+// determines which collection is searched first. Returns the token, its
+// type ("access_token" or "refresh_token") and whether it was found.
+func (cntrl *DefaultAPIController) findToken(id, hint string) (
+	authdb.TokenModel, string, bool) {
+	if common.IsJWT(id) {
+		id = cntrl.accessTokenID(id)
+		hint = "access_token"
+	}
+
+	order := []string{"access_token", "refresh_token"}
+	if hint == "refresh_token" {
+		order = []string{"refresh_token", "access_token"}
+	}
+
+	for _, kind := range order {
+		var token authdb.TokenModel
+		var err error
+		if kind == "access_token" {
+			token, err = cntrl.db.Tokens().FindAccessByID(id)
+		} else {
+			token, err = cntrl.db.Tokens().FindRefreshByID(id)
+		}
+
+		if err == nil {
+			return token, kind, true
+		}
+	}
+
+	return authdb.TokenModel{}, "", false
+}
+
+// tokenScope returns the scope that token is authorized for. Fails if the
+// client that token was issued to no longer exists.
+func (cntrl *DefaultAPIController) tokenScope(token authdb.TokenModel) (
+	[]string, error) {
+	client := authmw.DashboardClient
+	if token.ClientID != authmw.DashboardClient.ID {
+		var err error
+		client, err = cntrl.db.Clients().FindByID(token.ClientID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Tokens issued before scopes were recorded carry the client's
+	// full scope, see authmw.X.
+	if len(token.Scope) == 0 {
+		return grantScope(client, nil), nil
+	}
+
+	return token.Scope, nil
+}
+
+// canIntrospect reports whether client may learn the metadata of token:
+// it must be the client that token was issued to, be in its audience or
+// have registered the introspection scope.
+func (cntrl *DefaultAPIController) canIntrospect(client authdb.ClientModel,
+	token authdb.TokenModel) bool {
+	return token.ClientID == client.ID ||
+		cntrl.inAudience(client, token.Audience) ||
+		hasScope(client.Scope, introspectionScope)
+}
+
+// IntrospectionRoute returns the metadata of an access or refresh token
+// to an authenticated client. Tokens that the client may not introspect
+// are reported as inactive.
+// See: https://datatracker.ietf.org/doc/html/rfc7662
+func (cntrl *DefaultAPIController) IntrospectionRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		id := c.PostForm("token")
+		if id == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_request",
+				"error_description": "missing token parameter",
+			})
+			return
+		}
+
+		clientAny, _ := c.Get("client")
+		client, _ := clientAny.(authdb.ClientModel)
+
+		inactive := gin.H{"active": false}
+		token, kind, ok := cntrl.findToken(id, c.PostForm("token_type_hint"))
+		if !ok || !cntrl.canIntrospect(client, token) {
+			c.JSON(http.StatusOK, inactive)
+			return
+		}
+
+		// Token must not be expired or rotated out.
+		exp := token.CreatedAt + token.TTL
+		if exp < time.Now().Unix() || token.Rotated {
+			c.JSON(http.StatusOK, inactive)
+			return
+		}
+
+		// Associated user must still exist.
+		if token.UserID != "" {
+			if _, err := cntrl.db.Users().FindByID(token.UserID); err != nil {
+				c.JSON(http.StatusOK, inactive)
+				return
+			}
+		}
+
+		// Associated client must still exist.
+		scope, err := cntrl.tokenScope(token)
+		if err != nil {
+			c.JSON(http.StatusOK, inactive)
+			return
+		}
+
+		res := gin.H{
+			"active":    true,
+			"scope":     strings.Join(scope, " "),
+			"client_id": token.ClientID,
+			"exp":       exp,
+			"iat":       token.CreatedAt,
+			"iss":       cntrl.config.Issuer,
+		}
+
+		if token.UserID != "" {
+			res["sub"] = token.UserID
+		}
+
+		if len(token.Audience) > 0 {
+			res["aud"] = token.Audience
+		}
+
+		if act := common.NewActorClaims(token.Actors); act != nil {
+			res["act"] = act
+		}
+
+		if kind == "access_token" {
+			res["token_type"] = "bearer"
+		}
+
+		if token.JKT != "" {
+			res["token_type"] = "DPoP"
+		}
+
+		if token.JKT != "" || token.X5T != "" {
+			res["cnf"] = common.Confirm{JKT: token.JKT, X5T: token.X5T}
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
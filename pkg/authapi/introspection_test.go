@@ -0,0 +1,138 @@
+package authapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// introspect posts token to IntrospectionRoute as the client with callerID.
+func introspect(db *testDB, callerID, token string) map[string]interface{} {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/auth/introspect",
+		strings.NewReader(url.Values{"token": {token}}.Encode()))
+	c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c.Set("client", db.clients.clients[callerID])
+	(&DefaultAPIController{db: db}).IntrospectionRoute()(c)
+
+	res := map[string]interface{}{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	return res
+}
+
+func genIntrospectionDB() *testDB {
+	db := newTestDB()
+	db.users.users["user"] = authdb.UserModel{ID: "user"}
+	db.resources.resources["https://api.example.com"] = authdb.ResourceModel{
+		Audience: "https://api.example.com", ClientID: "resource",
+	}
+	for _, id := range []string{"client", "resource", "audience", "other"} {
+		db.clients.clients[id] = authdb.ClientModel{ID: id, Scope: []string{"public"}}
+	}
+	db.clients.clients["introspector"] = authdb.ClientModel{
+		ID: "introspector", Scope: []string{"public", introspectionScope},
+	}
+	db.tokens.access["token"] = authdb.TokenModel{
+		ID:        "token",
+		ClientID:  "client",
+		UserID:    "user",
+		Scope:     []string{"public"},
+		Audience:  []string{"https://api.example.com", "audience"},
+		CreatedAt: time.Now().Unix(),
+		TTL:       1200,
+	}
+	return db
+}
+
+func TestIntrospection(t *testing.T) {
+	db := genIntrospectionDB()
+	for _, caller := range []string{"client", "resource", "audience", "introspector"} {
+		if res := introspect(db, caller, "token"); res["active"] != true {
+			t.Errorf("%s could not introspect the token: %v", caller, res)
+		}
+	}
+}
+
+func TestIntrospectionOtherClient(t *testing.T) {
+	res := introspect(genIntrospectionDB(), "other", "token")
+	if res["active"] != false || len(res) != 1 {
+		t.Fatalf("unrelated client learned about the token: %v", res)
+	}
+}
+
+func TestIntrospectionScopeNotGranted(t *testing.T) {
+	client := authdb.ClientModel{Scope: []string{"public", introspectionScope}}
+	if scope := grantScope(client, nil); len(scope) != 1 || scope[0] != "public" {
+		t.Fatalf("introspection scope granted by default: %v", scope)
+	}
+
+	requested := []string{introspectionScope, "public"}
+	if scope := grantScope(client, requested); len(scope) != 1 || scope[0] != "public" {
+		t.Fatalf("introspection scope granted on request: %v", scope)
+	}
+}
+
+func TestIntrospectionScopeRegistration(t *testing.T) {
+	var client authdb.ClientModel
+	req := registrationRequest{Scope: "public " + introspectionScope}
+	if code, _ := req.apply(&client, []string{"client_secret_basic"}); code == "" {
+		t.Fatalf("introspection scope registered dynamically")
+	}
+}
+
+// createClient posts an introspection client to CreateClientRoute as user.
+func createClient(db *testDB, user authdb.UserModel) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(gin.H{
+		"name":          "Resource",
+		"description":   "Resource server",
+		"response_type": "code",
+		"redirect_uris": []string{"https://api.example.com/callback"},
+		"scope":         []string{"public", introspectionScope},
+	})
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/client", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user", user)
+	(&DefaultAPIController{db: db}).CreateClientRoute()(c)
+	return w
+}
+
+func TestCreateIntrospectionClient(t *testing.T) {
+	db := newTestDB()
+	w := createClient(db, authdb.UserModel{
+		ID: "admin", Realms: []string{"clients.create", "clients.introspect"},
+	})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("administrator could not create client: %s", w.Body.String())
+	}
+
+	if client := db.clients.clients["Resource"]; !hasScope(client.Scope, introspectionScope) {
+		t.Fatalf("client created without introspection scope: %v", client.Scope)
+	}
+}
+
+func TestCreateIntrospectionClientUnauthorized(t *testing.T) {
+	db := newTestDB()
+	w := createClient(db, authdb.UserModel{ID: "user", Realms: []string{"clients.create"}})
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without clients.introspect, got %d", w.Code)
+	}
+
+	if len(db.clients.clients) != 0 {
+		t.Fatalf("introspection client created without clients.introspect")
+	}
+}
@@ -0,0 +1,151 @@
+package authapi
+
+import (
+	"fmt"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/authmw"
+	"github.com/ufosc/OpenWebServices/pkg/common"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Back-channel logout delivery. Logout tokens are valid for 2 minutes and
+// delivery is attempted logoutAttempts times, doubling the delay between
+// attempts from logoutRetryDelay.
+const (
+	logoutTokenTTL   = 120
+	logoutAttempts   = 4
+	logoutRetryDelay = 2 * time.Second
+)
+
+// validLogoutURI reports whether uri can be registered as a logout endpoint:
+// an https URL without a fragment. Loopback URLs may use http.
+func validLogoutURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" || u.Fragment != "" || strings.Contains(uri, "#") {
+		return false
+	}
+
+	if u.Scheme == "https" {
+		return true
+	}
+
+	ip := net.ParseIP(u.Hostname())
+	return u.Scheme == "http" &&
+		(u.Hostname() == "localhost" || (ip != nil && ip.IsLoopback()))
+}
+
+// validBackchannelLogoutURI reports whether uri can be registered as a
+// back-channel logout endpoint. The server POSTs logout tokens to it, so
+// unlike front-channel URLs it must be https on a public host.
+func validBackchannelLogoutURI(uri string) bool {
+	return validLogoutURI(uri) && authmw.ValidJWKSURI(uri)
+}
+
+// logoutClient delivers logout tokens to back-channel logout endpoints.
+var logoutClient = authmw.PublicHTTPClient("backchannel_logout_uri")
+
+// signedInClients returns the clients that hold live tokens on behalf of
+// a user, which are notified when the user signs out. The dashboard is not
+// included.
+func (cntrl *DefaultAPIController) signedInClients(userID string) (
+	[]authdb.ClientModel, error) {
+	tokens, err := cntrl.db.Tokens().FindByUser(userID, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, token := range tokens {
+		if token.ClientID != authmw.DashboardClient.ID && !hasScope(ids, token.ClientID) {
+			ids = append(ids, token.ClientID)
+		}
+	}
+
+	return cntrl.db.Clients().FindByIDs(ids)
+}
+
+// logoutClients notifies clients that a user signed out. Logout tokens are
+// delivered to back-channel endpoints in the background. Returns the
+// front-channel logout URLs that the user agent must load.
+func (cntrl *DefaultAPIController) logoutClients(userID string,
+	clients []authdb.ClientModel) []string {
+	frontchannel := []string{}
+	for _, client := range clients {
+		if client.FrontchannelLogoutURI != "" {
+			frontchannel = append(frontchannel, client.FrontchannelLogoutURI)
+		}
+
+		if client.BackchannelLogoutURI == "" {
+			continue
+		}
+
+		token, err := cntrl.createLogoutToken(client, userID)
+		if err != nil {
+			fmt.Println("cannot sign logout token for client", client.ID, err)
+			continue
+		}
+
+		go deliverLogout(client.BackchannelLogoutURI, token)
+	}
+
+	return frontchannel
+}
+
+// createLogoutToken signs a back-channel logout token for client.
+// See: https://openid.net/specs/openid-connect-backchannel-1_0.html#LogoutToken
+func (cntrl *DefaultAPIController) createLogoutToken(client authdb.ClientModel,
+	userID string) (string, error) {
+	now := time.Now().Unix()
+	return cntrl.config.SigningKey.Sign(common.LogoutTokenType,
+		common.LogoutTokenClaims{
+			Issuer:   cntrl.config.Issuer,
+			Subject:  userID,
+			Audience: client.ID,
+			IssuedAt: now,
+			Expiry:   now + logoutTokenTTL,
+			ID:       common.UUID(),
+			Events: map[string]struct{}{
+				common.BackchannelLogoutEvent: {},
+			},
+		})
+}
+
+// deliverLogout POSTs a logout token to a back-channel logout endpoint.
+// Delivery is retried on network and server errors, but not when the
+// client rejects the token.
+// See: https://openid.net/specs/openid-connect-backchannel-1_0.html#BCRequest
+func deliverLogout(uri, token string) {
+	// Endpoints registered before they were required to be public are
+	// skipped.
+	if !validBackchannelLogoutURI(uri) {
+		fmt.Println("not delivering logout token to non-public", uri)
+		return
+	}
+
+	delay := logoutRetryDelay
+	for attempt := 1; ; attempt++ {
+		resp, err := logoutClient.PostForm(uri, url.Values{"logout_token": {token}})
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+				if resp.StatusCode >= 300 {
+					fmt.Println("logout token rejected by", uri, resp.Status)
+				}
+				return
+			}
+			err = fmt.Errorf("unexpected status %s", resp.Status)
+		}
+
+		if attempt == logoutAttempts {
+			fmt.Println("cannot deliver logout token to", uri, err)
+			return
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
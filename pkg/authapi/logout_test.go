@@ -0,0 +1,80 @@
+package authapi
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/authmw"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestValidBackchannelLogoutURI(t *testing.T) {
+	if !validBackchannelLogoutURI("https://app.example.com/logout") {
+		t.Fatalf("public https logout URI rejected")
+	}
+}
+
+func TestValidBackchannelLogoutURIPrivateHost(t *testing.T) {
+	// The server POSTs to back-channel endpoints itself, so they must not
+	// name its own or internal services.
+	uris := []string{
+		"http://localhost:3001/logout",
+		"https://localhost:3001/logout",
+		"http://127.0.0.1/logout",
+		"https://127.0.0.1/logout",
+		"https://10.0.0.1/logout",
+		"https://169.254.169.254/latest/meta-data",
+		"https://[::1]/logout",
+		"http://app.example.com/logout",
+		"https://app.example.com/logout#fragment",
+	}
+
+	for _, uri := range uris {
+		if validBackchannelLogoutURI(uri) {
+			t.Errorf("accepted back-channel logout URI %s", uri)
+		}
+	}
+}
+
+func TestValidLogoutURILoopback(t *testing.T) {
+	// Front-channel URLs are loaded by the user agent, so native apps may
+	// use loopback ones.
+	if !validLogoutURI("http://127.0.0.1:8000/logout") {
+		t.Fatalf("loopback front-channel logout URI rejected")
+	}
+}
+
+func TestSignOutRevokesClients(t *testing.T) {
+	now := time.Now().Unix()
+	db := newTestDB()
+	db.clients.clients["client"] = authdb.ClientModel{ID: "client"}
+	db.tokens.access["dashboard"] = authdb.TokenModel{
+		ID: "dashboard", ClientID: authmw.DashboardClient.ID, UserID: "user",
+		CreatedAt: now, TTL: 1200,
+	}
+	db.tokens.access["client"] = authdb.TokenModel{
+		ID: "client", ClientID: "client", UserID: "user", CreatedAt: now, TTL: 1200,
+	}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/auth/signout", nil)
+	c.Set("user", authdb.UserModel{ID: "user"})
+	c.Set("token", db.tokens.access["dashboard"])
+	(&DefaultAPIController{db: db}).SignOutRoute()(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("sign out failed: %d %s", w.Code, w.Body.String())
+	}
+
+	if _, ok := db.tokens.access["dashboard"]; ok {
+		t.Errorf("dashboard token not revoked")
+	}
+
+	if len(db.tokens.revoked) != 1 || db.tokens.revoked[0] != "user:client" {
+		t.Errorf("client tokens not revoked: %v", db.tokens.revoked)
+	}
+}
@@ -0,0 +1,74 @@
+package authapi
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authmw"
+	"github.com/ufosc/OpenWebServices/pkg/common"
+	"net/http"
+	"sort"
+)
+
+// clientAuthMethods returns the client authentication methods accepted by
+// the routes that authenticate clients with authmw.C. Certificates are only
+// accepted if they reach the server.
+func (cntrl *DefaultAPIController) clientAuthMethods() []string {
+	methods := []string{"client_secret_basic", "client_secret_post", authmw.PrivateKeyJWT}
+	if cntrl.config.ClientCertificates {
+		methods = append(methods, authmw.TLSClientAuth, authmw.SelfSignedTLSClientAuth)
+	}
+	return methods
+}
+
+// metadata describes the authorization server. It is derived from the
+// server configuration and the grant types, response types and scopes
+// that the routes accept.
+func (cntrl *DefaultAPIController) metadata() gin.H {
+	issuer := cntrl.config.Issuer
+	authMethods := cntrl.clientAuthMethods()
+
+	grantTypes := []string{}
+	for grantType := range cntrl.grants() {
+		grantTypes = append(grantTypes, grantType)
+	}
+
+	responseTypes := common.ResponseTypes()
+	for _, resType := range responseTypes {
+		if resType == "token" {
+			grantTypes = append(grantTypes, "implicit")
+		}
+	}
+	sort.Strings(grantTypes)
+
+	return gin.H{
+		"issuer":                                issuer,
+		"authorization_endpoint":                cntrl.config.Frontend + "/authorize",
+		"token_endpoint":                        issuer + "/auth/token",
+		"jwks_uri":                              issuer + "/.well-known/jwks.json",
+		"scopes_supported":                      append([]string{"openid"}, common.Scopes()...),
+		"response_types_supported":              responseTypes,
+		"response_modes_supported":              responseModes,
+		"grant_types_supported":                 grantTypes,
+		"token_endpoint_auth_methods_supported": append([]string{"none"}, authMethods...),
+		"token_endpoint_auth_signing_alg_values_supported": common.ClientAssertionSigningAlgs,
+		"introspection_endpoint":                           issuer + "/auth/introspect",
+		"introspection_endpoint_auth_methods_supported":    authMethods,
+		"revocation_endpoint":                              issuer + "/auth/revoke",
+		"revocation_endpoint_auth_methods_supported":       authMethods,
+		"pushed_authorization_request_endpoint":            issuer + "/auth/par",
+		"require_pushed_authorization_requests":            false,
+		"registration_endpoint":                            issuer + "/register",
+		"device_authorization_endpoint":                    issuer + "/auth/device",
+		"code_challenge_methods_supported":                 []string{common.PKCEPlain, common.PKCES256},
+		"dpop_signing_alg_values_supported":                common.DPoPSigningAlgs,
+		"tls_client_certificate_bound_access_tokens":       cntrl.config.ClientCertificates,
+	}
+}
+
+// AuthorizationServerMetadataRoute returns the OAuth 2.0 authorization
+// server metadata.
+// See: https://datatracker.ietf.org/doc/html/rfc8414
+func (cntrl *DefaultAPIController) AuthorizationServerMetadataRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, cntrl.metadata())
+	}
+}
@@ -1,13 +1,13 @@
 package authapi
 
 import (
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/ufosc/OpenWebServices/pkg/authdb"
 	"github.com/ufosc/OpenWebServices/pkg/authmw"
 	"github.com/ufosc/OpenWebServices/pkg/common"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
@@ -15,18 +15,44 @@ import (
 func (cntrl *DefaultAPIController) AuthorizationRoute() gin.HandlerFunc {
 	return func(c *gin.Context) {
 
-		// Get underlying user.
+		// Get underlying user and their dashboard session.
 		userAny, _ := c.Get("user")
 		user, _ := userAny.(authdb.UserModel)
+		sessionAny, _ := c.Get("token")
+		session, _ := sessionAny.(authdb.TokenModel)
 
-		// Gather query parameters.
-		responseType := c.DefaultQuery("response_type", "")
+		// Gather request parameters. Pushed authorization requests
+		// replace the query string, except for client_id.
 		clientID := c.DefaultQuery("client_id", "")
-		redirectURI := c.DefaultQuery("redirect_uri", "")
-		state := c.DefaultQuery("state", "")
+		requestURI := c.DefaultQuery("request_uri", "")
+		param := func(key string) string {
+			return c.DefaultQuery(key, "")
+		}
+		resources := splitResources(c.QueryArray("resource"))
+
+		if requestURI != "" {
+			pushed, ok := cntrl.pushedRequest(c, clientID, requestURI)
+			if !ok {
+				return
+			}
+
+			param = func(key string) string {
+				return pushed.Params[key]
+			}
+			resources = strings.Fields(pushed.Params["resource"])
+		}
+
+		responseType := param("response_type")
+		redirectURI := param("redirect_uri")
+		state := param("state")
+		challenge := param("code_challenge")
+		challengeMethod := param("code_challenge_method")
+		scope := strings.Fields(param("scope"))
+		nonce := param("nonce")
+		mode := param("response_mode")
 
 		// Validate response type
-		if responseType != "code" && responseType != "token" {
+		if !common.ValidateResponseType(responseType) {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error":             "invalid_request",
 				"error_description": "response_type must be 'code' or 'token'",
@@ -53,6 +79,14 @@ func (cntrl *DefaultAPIController) AuthorizationRoute() gin.HandlerFunc {
 			return
 		}
 
+		if client.RequirePAR && requestURI == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_request",
+				"error_description": "this client requires pushed authorization requests",
+			})
+			return
+		}
+
 		redirectDecoded, err := url.QueryUnescape(redirectURI)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
@@ -62,8 +96,8 @@ func (cntrl *DefaultAPIController) AuthorizationRoute() gin.HandlerFunc {
 			return
 		}
 
-		// Verify request redirect_uri matches client redirect_uri.
-		if client.RedirectURI != redirectDecoded {
+		// Verify request redirect_uri is registered by the client.
+		if !common.MatchRedirectURI(client.RedirectURIs, redirectDecoded) {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error":            "invalid_request",
 				"error_descriptor": "wrong redirect_uri",
@@ -73,12 +107,61 @@ func (cntrl *DefaultAPIController) AuthorizationRoute() gin.HandlerFunc {
 
 		// Redirect to the client redirect_uri whenever possible (when
 		// the integrity of redirect_uri can be verified).
+		mode, modeOK := responseMode(responseType, mode)
+		if !modeOK {
+			mode, _ = responseMode(responseType, "")
+		}
+
+		respond := func(params url.Values) {
+			params.Set("state", state)
+			authorizationResponse(c, redirectDecoded, mode, params)
+		}
+
+		if !modeOK {
+			respond(url.Values{
+				"error":             {"invalid_request"},
+				"error_description": {"unsupported response_mode"},
+			})
+			return
+		}
 
 		// Verify request response_type matches client configuration.
 		if client.ResponseType != responseType {
-			redirect := fmt.Sprintf("%s?error=invalid_request&state=%s",
-				client.RedirectURI, state)
-			c.Redirect(http.StatusFound, redirect)
+			respond(url.Values{"error": {"invalid_request"}})
+			return
+		}
+
+		// Validate PKCE parameters. The transformation method defaults
+		// to plain, see: https://datatracker.ietf.org/doc/html/rfc7636#section-4.3
+		if client.ResponseType == "code" && (challenge != "" || challengeMethod != "") {
+			if challengeMethod == "" {
+				challengeMethod = common.PKCEPlain
+			}
+
+			if !common.ValidateCodeChallenge(challengeMethod, challenge) {
+				respond(url.Values{"error": {"invalid_request"}})
+				return
+			}
+		}
+
+		// Downscope the request to the client's registered scope.
+		granted := grantScope(client, scope)
+		if len(granted) == 0 {
+			respond(url.Values{"error": {"invalid_scope"}})
+			return
+		}
+
+		// Restrict tokens to the requested resource servers.
+		// See: https://datatracker.ietf.org/doc/html/rfc8707#section-2.1
+		audience, ok := cntrl.targetAudience(resources, nil)
+		if !ok {
+			respond(url.Values{"error": {"invalid_target"}})
+			return
+		}
+
+		// Record that the user consented to the granted scope.
+		if err := cntrl.db.Consents().Grant(user.ID, client.ID, granted); err != nil {
+			respond(url.Values{"error": {"server_error"}})
 			return
 		}
 
@@ -90,99 +173,209 @@ func (cntrl *DefaultAPIController) AuthorizationRoute() gin.HandlerFunc {
 				UserID:    user.ID,
 				CreatedAt: time.Now().Unix(),
 				TTL:       1200,
+				Scope:     granted,
+				Audience:  audience,
 			}
 
 			// Save to db.
-			id, err := cntrl.db.Tokens().CreateAccess(token)
+			id, err := cntrl.createAccess(token)
 			if err != nil {
-				redirect := fmt.Sprintf("%s?error=server_error&state=%s",
-					client.RedirectURI, state)
-				c.Redirect(http.StatusFound, redirect)
+				respond(url.Values{"error": {"server_error"}})
 				return
 			}
 
 			// Redirect user.
-			uri := fmt.Sprintf("%s?access_token=%s&token_type=bearer&expires_in=1200&state=%s",
-				client.RedirectURI, id, state)
-
-			c.Redirect(http.StatusFound, uri)
+			respond(url.Values{
+				"access_token": {id},
+				"token_type":   {"bearer"},
+				"expires_in":   {"1200"},
+				"scope":        {strings.Join(granted, " ")},
+			})
 			return
 		}
 
 		// Create authorization code.
 		code := authdb.TokenModel{
-			ID:        common.UUID(),
-			ClientID:  client.ID,
-			UserID:    user.ID,
-			CreatedAt: time.Now().Unix(),
-			TTL:       600,
+			ID:                  common.UUID(),
+			ClientID:            client.ID,
+			UserID:              user.ID,
+			CreatedAt:           time.Now().Unix(),
+			TTL:                 600,
+			CodeChallenge:       challenge,
+			CodeChallengeMethod: challengeMethod,
+			RedirectURI:         redirectDecoded,
+			Scope:               granted,
+			Audience:            audience,
+		}
+
+		// OpenID Connect authentication request.
+		if hasScope(granted, "openid") {
+			code.Nonce = nonce
+			code.AuthTime = session.CreatedAt
 		}
 
 		// Save to DB.
 		id, err := cntrl.db.Tokens().CreateAuth(code)
 		if err != nil {
-			redirect := fmt.Sprintf("%s?error=server_error&state=%s",
-				client.RedirectURI, state)
-			c.Redirect(http.StatusFound, redirect)
+			respond(url.Values{"error": {"server_error"}})
 			return
 		}
 
 		// Redirect user.
-		uri := fmt.Sprintf("%s?code=%s&state=%s", client.RedirectURI,
-			id, state)
+		respond(url.Values{"code": {id}})
+	}
+}
+
+// grants maps each grant type accepted by the token route to its handler.
+func (cntrl *DefaultAPIController) grants() map[string]gin.HandlerFunc {
+	return map[string]gin.HandlerFunc{
+		"authorization_code": cntrl.handleAuthCode,
+		"refresh_token":      cntrl.handleRefreshToken,
+		"client_credentials": cntrl.handleClientCredentials,
+		deviceCodeGrant:      cntrl.handleDeviceCode,
+		tokenExchangeGrant:   cntrl.handleTokenExchange,
+	}
+}
+
+// tokenParams returns the values of a token request parameter. Token
+// requests are form-encoded POST bodies, or query strings if legacy GET
+// requests are allowed.
+func tokenParams(c *gin.Context, key string) []string {
+	if c.Request.Method == http.MethodGet {
+		return c.QueryArray(key)
+	}
+	return c.PostFormArray(key)
+}
 
-		c.Redirect(http.StatusFound, uri)
+// tokenParam returns the first value of a token request parameter.
+func tokenParam(c *gin.Context, key string) string {
+	if values := tokenParams(c, key); len(values) > 0 {
+		return values[0]
 	}
+	return ""
 }
 
 // TokenRoute returns the gin middleware for the Oauth2 token route.
+// See: https://datatracker.ietf.org/doc/html/rfc6749#section-3.2
 func (cntrl *DefaultAPIController) TokenRoute() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// See: https://datatracker.ietf.org/doc/html/rfc6749#section-5.1
+		c.Header("Cache-Control", "no-store")
+		c.Header("Pragma", "no-cache")
 
-		grantType := c.DefaultQuery("grant_type", "")
-		if grantType == "authorization_code" {
-			cntrl.handleAuthCode(c)
+		if c.Request.Method == http.MethodGet && !cntrl.config.LegacyTokenGET {
+			c.JSON(http.StatusMethodNotAllowed, gin.H{
+				"error":             "invalid_request",
+				"error_description": "token requests must be form-encoded POST requests",
+			})
+			return
+		}
+
+		if c.Request.Method == http.MethodPost &&
+			c.ContentType() != "application/x-www-form-urlencoded" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_request",
+				"error_description": "expected application/x-www-form-urlencoded request body",
+			})
 			return
 		}
 
-		if grantType == "refresh_token" {
-			cntrl.handleRefreshToken(c)
+		jkt, ok := cntrl.dpopKey(c)
+		if !ok {
 			return
 		}
+		c.Set("dpop_jkt", jkt)
 
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "invalid grant_type",
+		grantType := tokenParam(c, "grant_type")
+		if handle, ok := cntrl.grants()[grantType]; ok {
+			handle(c)
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "unsupported_grant_type",
+			"error_description": "grant_type is missing or not supported",
 		})
 	}
 }
 
-func (cntrl *DefaultAPIController) handleAuthCode(c *gin.Context) {
-	authmw.B(cntrl.db)(c)
-	if c.IsAborted() {
-		return
+// publicClient finds an unauthenticated (public) client by its ID. It
+// writes an error response and returns false if the client is not found,
+// has expired, or was not registered as a public client.
+func (cntrl *DefaultAPIController) publicClient(c *gin.Context, id string) (
+	authdb.ClientModel, bool) {
+	client, err := cntrl.db.Clients().FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":             "invalid_client",
+			"error_description": "client ID not found",
+		})
+		return authdb.ClientModel{}, false
 	}
 
-	// Get underlying client.
-	clientAny, ok := c.Get("client")
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":             "not_found",
-			"error_description": "client ID not found",
+	// Client must not be expired.
+	if (client.CreatedAt + client.TTL) < time.Now().Unix() {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":             "invalid_client",
+			"error_description": "client has expired",
 		})
-		return
+		return authdb.ClientModel{}, false
 	}
 
-	// Cast to client model.
-	client, ok := clientAny.(authdb.ClientModel)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":             "not_found",
-			"error_description": "client ID not found",
+	// Confidential clients must always authenticate.
+	if !authmw.IsPublic(client) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":             "invalid_client",
+			"error_description": "client authentication is required",
 		})
-		return
+		return authdb.ClientModel{}, false
 	}
 
-	grantType := c.DefaultQuery("grant_type", "")
+	return client, true
+}
+
+func (cntrl *DefaultAPIController) handleAuthCode(c *gin.Context) {
+	// Public clients cannot keep a secret, so they redeem codes without
+	// client authentication by proving possession of the PKCE code
+	// verifier.
+	verifier := tokenParam(c, "code_verifier")
+	public := !authmw.HasCredentials(c)
+
+	var client authdb.ClientModel
+	if public {
+		var ok bool
+		client, ok = cntrl.publicClient(c, tokenParam(c, "client_id"))
+		if !ok {
+			return
+		}
+	} else {
+		authmw.C(cntrl.db, cntrl.config.Issuer)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		// Get underlying client.
+		clientAny, ok := c.Get("client")
+		if !ok {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "not_found",
+				"error_description": "client ID not found",
+			})
+			return
+		}
+
+		// Cast to client model.
+		client, ok = clientAny.(authdb.ClientModel)
+		if !ok {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "not_found",
+				"error_description": "client ID not found",
+			})
+			return
+		}
+	}
+
+	grantType := tokenParam(c, "grant_type")
 	if grantType != "authorization_code" {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":             "invalid_request",
@@ -191,9 +384,9 @@ func (cntrl *DefaultAPIController) handleAuthCode(c *gin.Context) {
 		return
 	}
 
-	code := c.DefaultQuery("code", "")
-	redirectUri := c.DefaultQuery("redirect_uri", "")
-	clientID := c.DefaultQuery("client_id", "")
+	code := tokenParam(c, "code")
+	redirectUri := tokenParam(c, "redirect_uri")
+	clientID := tokenParam(c, "client_id")
 
 	// Ensure required params are non-nil.
 	if code == "" || redirectUri == "" || clientID == "" {
@@ -204,11 +397,15 @@ func (cntrl *DefaultAPIController) handleAuthCode(c *gin.Context) {
 		return
 	}
 
-	// Validate code.
-	codeExists, err := cntrl.db.Tokens().FindAuthByID(code)
+	// Redeem code. Codes are deleted as they are redeemed, so a code
+	// that cannot be found may have been used before. Tokens issued
+	// from a code that is presented again are revoked.
+	// See: https://datatracker.ietf.org/doc/html/rfc6749#section-4.1.2
+	codeExists, err := cntrl.db.Tokens().ConsumeAuth(code)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error":             "not_found",
+		cntrl.db.Tokens().DeleteByCode(code)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_grant",
 			"error_description": "Token expired or could not be found",
 		})
 		return
@@ -216,55 +413,99 @@ func (cntrl *DefaultAPIController) handleAuthCode(c *gin.Context) {
 
 	// Ensure code has not expired.
 	if (codeExists.CreatedAt + codeExists.TTL) < time.Now().Unix() {
-		cntrl.db.Tokens().DeleteAuthByID(codeExists.ID)
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error":             "not_found",
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_grant",
 			"error_description": "Token expired or could not be found",
 		})
 		return
 	}
 
-	// Ensure client IDs match.
-	if clientID != codeExists.ClientID {
-		cntrl.db.Tokens().DeleteAuthByID(codeExists.ID)
+	// Ensure the code was issued to the client redeeming it.
+	if client.ID != codeExists.ClientID {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error":             "unauthorized",
+			"error":             "invalid_grant",
 			"error_description": "Token was not issued to this client",
 		})
 		return
 	}
 
+	// Verify PKCE code verifier. Codes issued without a challenge can
+	// only be redeemed by confidential clients.
+	if public && codeExists.CodeChallenge == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_grant",
+			"error_description": "public clients must use PKCE",
+		})
+		return
+	}
+
+	if codeExists.CodeChallenge != "" {
+		if !common.VerifyCodeVerifier(codeExists.CodeChallengeMethod,
+			codeExists.CodeChallenge, verifier) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_grant",
+				"error_description": "code_verifier does not match code_challenge",
+			})
+			return
+		}
+	} else if verifier != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_request",
+			"error_description": "code_verifier provided but no code_challenge was registered",
+		})
+		return
+	}
+
 	// Ensure client id exists.
-	clientExists, err := cntrl.db.Clients().FindByID(clientID)
+	clientExists, err := cntrl.db.Clients().FindByID(client.ID)
 	if err != nil {
-		cntrl.db.Tokens().DeleteAuthByID(codeExists.ID)
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error":             "not_found",
+			"error":             "invalid_grant",
 			"error_description": "The client associated with this token could not be found",
 		})
 		return
 	}
 
-	// Ensure redirectURI is the same as client.
-	if clientExists.RedirectURI != redirectUri {
-		cntrl.db.Tokens().DeleteAuthByID(codeExists.ID)
+	// Ensure redirectURI is the one that the code was requested with.
+	// See: https://datatracker.ietf.org/doc/html/rfc6749#section-4.1.3
+	if codeExists.RedirectURI != redirectUri ||
+		!common.MatchRedirectURI(clientExists.RedirectURIs, redirectUri) {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error":             "invalid_request",
-			"error_description": "redirect_uri and client-registered redirect_uri do not match",
+			"error":             "invalid_grant",
+			"error_description": "redirect_uri does not match the authorization request",
 		})
 		return
 	}
 
 	// Ensure userID still exists.
-	if _, err := cntrl.db.Users().FindByID(codeExists.UserID); err != nil {
-		cntrl.db.Tokens().DeleteAuthByID(codeExists.ID)
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":             "not_found",
+	user, err := cntrl.db.Users().FindByID(codeExists.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_grant",
 			"error_description": "The user associated with this token could not be found",
 		})
 		return
 	}
 
+	// Access tokens can be narrowed to some of the resources that the
+	// code was issued for, refresh tokens keep all of them.
+	audience, ok := cntrl.targetAudience(
+		splitResources(tokenParams(c, "resource")), codeExists.Audience)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_target",
+			"error_description": "resource was not requested at the authorization endpoint",
+		})
+		return
+	}
+
+	// Public clients cannot authenticate at the refresh token grant,
+	// so they are only issued an access token.
+	refreshID := ""
+	if !public {
+		refreshID = common.UUID()
+	}
+
 	// Create access token.
 	atoken := authdb.TokenModel{
 		ID:        common.UUID(),
@@ -272,11 +513,17 @@ func (cntrl *DefaultAPIController) handleAuthCode(c *gin.Context) {
 		UserID:    codeExists.UserID,
 		CreatedAt: time.Now().Unix(),
 		TTL:       1200,
+		Scope:     codeExists.Scope,
+		Audience:  audience,
+		RefreshID: refreshID,
+		FamilyID:  refreshID,
+		JKT:       dpopJKT(c),
+		X5T:       authmw.CertificateThumbprint(c),
+		CodeID:    codeExists.ID,
 	}
 
-	aid, err := cntrl.db.Tokens().CreateAccess(atoken)
+	aid, err := cntrl.createAccess(atoken)
 	if err != nil {
-		cntrl.db.Tokens().DeleteAuthByID(codeExists.ID)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":             "internal_server_error",
 			"error_description": "Internal server error. Please try again later",
@@ -284,19 +531,49 @@ func (cntrl *DefaultAPIController) handleAuthCode(c *gin.Context) {
 		return
 	}
 
+	res := gin.H{
+		"message":      "success",
+		"access_token": aid,
+		"token_type":   tokenType(c),
+		"expires_in":   1200,
+		"scope":        strings.Join(codeExists.Scope, " "),
+	}
+
+	// Issue an ID token for OpenID Connect requests.
+	if hasScope(codeExists.Scope, "openid") {
+		idToken, err := cntrl.createIDToken(clientExists, user, codeExists)
+		if err != nil {
+			cntrl.db.Tokens().DeleteAccessByID(atoken.ID)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "Internal server error. Please try again later",
+			})
+			return
+		}
+		res["id_token"] = idToken
+	}
+
+	if public {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
 	// Create refresh token.
 	rtoken := authdb.TokenModel{
-		ID:        common.UUID(),
+		ID:        refreshID,
 		ClientID:  client.ID,
 		UserID:    codeExists.UserID,
 		CreatedAt: time.Now().Unix(),
 		TTL:       5256000,
+		Scope:     codeExists.Scope,
+		Audience:  codeExists.Audience,
+		FamilyID:  refreshID,
+		CodeID:    codeExists.ID,
 	}
 
 	rid, err := cntrl.db.Tokens().CreateRefresh(rtoken)
 	if err != nil {
-		cntrl.db.Tokens().DeleteAccessByID(aid)
-		cntrl.db.Tokens().DeleteAuthByID(codeExists.ID)
+		cntrl.db.Tokens().DeleteAccessByID(atoken.ID)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":             "internal_server_error",
 			"error_description": "Internal server error. Please try again later",
@@ -304,17 +581,12 @@ func (cntrl *DefaultAPIController) handleAuthCode(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":       "success",
-		"access_token":  aid,
-		"token_type":    "bearer",
-		"expires_in":    1200,
-		"refresh_token": rid,
-	})
+	res["refresh_token"] = rid
+	c.JSON(http.StatusOK, res)
 }
 
 func (cntrl *DefaultAPIController) handleRefreshToken(c *gin.Context) {
-	authmw.B(cntrl.db)(c)
+	authmw.C(cntrl.db, cntrl.config.Issuer)(c)
 	if c.IsAborted() {
 		return
 	}
@@ -339,7 +611,7 @@ func (cntrl *DefaultAPIController) handleRefreshToken(c *gin.Context) {
 		return
 	}
 
-	grantType := c.DefaultQuery("grant_type", "")
+	grantType := tokenParam(c, "grant_type")
 	if grantType != "refresh_token" {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":             "invalid_request",
@@ -348,7 +620,7 @@ func (cntrl *DefaultAPIController) handleRefreshToken(c *gin.Context) {
 		return
 	}
 
-	refreshToken := c.DefaultQuery("refresh_token", "")
+	refreshToken := tokenParam(c, "refresh_token")
 	if refreshToken == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":             "invalid_request",
@@ -361,17 +633,17 @@ func (cntrl *DefaultAPIController) handleRefreshToken(c *gin.Context) {
 	token, err := cntrl.db.Tokens().FindRefreshByID(refreshToken)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error":             "not_found",
+			"error":             "invalid_grant",
 			"error_description": "Refresh token expired or could not be found",
 		})
 		return
 	}
 
 	// Ensure token is not expired.
-	if (token.CreatedAt + token.TTL) > time.Now().Unix() {
+	if (token.CreatedAt + token.TTL) < time.Now().Unix() {
 		cntrl.db.Tokens().DeleteRefreshByID(token.ID)
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error":             "not_found",
+			"error":             "invalid_grant",
 			"error_description": "Refresh token expired or could not be found",
 		})
 		return
@@ -380,23 +652,79 @@ func (cntrl *DefaultAPIController) handleRefreshToken(c *gin.Context) {
 	// Ensure that the refresh token was issued to the
 	// authenticated client.
 	if token.ClientID != client.ID {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error":             "not_found",
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_grant",
 			"error_description": "Refresh token was not issued to this client",
 		})
 		return
 	}
 
+	// Access tokens can be narrowed to some of the resources that the
+	// refresh token was issued for.
+	audience, ok := cntrl.targetAudience(
+		splitResources(tokenParams(c, "resource")), token.Audience)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_target",
+			"error_description": "resource was not granted to this refresh token",
+		})
+		return
+	}
+
+	// Refresh tokens are single use. Presenting a token that was already
+	// rotated out means that it leaked, so the whole family is revoked.
+	// See: https://datatracker.ietf.org/doc/html/draft-ietf-oauth-security-topics#section-4.14.2
+	rotated := false
+	if !token.Rotated {
+		rotated, err = cntrl.db.Tokens().RotateRefresh(token.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "internal server error. Please try again later",
+			})
+			return
+		}
+	}
+
+	if !rotated {
+		cntrl.revokeFamily(token)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_grant",
+			"error_description": "Refresh token has already been used",
+		})
+		return
+	}
+
 	// Ensure associated user still exists.
 	if _, err := cntrl.db.Users().FindByID(token.UserID); err != nil {
-		cntrl.db.Tokens().DeleteRefreshByID(token.ID)
+		cntrl.revokeFamily(token)
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error":             "not_found",
+			"error":             "invalid_grant",
 			"error_description": "the user associated with this token could not be found",
 		})
 		return
 	}
 
+	// Tokens issued before rotation was introduced start a new family.
+	family := token.FamilyID
+	if family == "" {
+		family = token.ID
+	}
+
+	// Create new refresh token to replace the presented one.
+	rtoken := authdb.TokenModel{
+		ID:        common.UUID(),
+		ClientID:  client.ID,
+		UserID:    token.UserID,
+		CreatedAt: time.Now().Unix(),
+		TTL:       5256000,
+		Scope:     token.Scope,
+		Audience:  token.Audience,
+		FamilyID:  family,
+		ParentID:  token.ID,
+		CodeID:    token.CodeID,
+	}
+
 	// Create new access token.
 	atoken := authdb.TokenModel{
 		ID:        common.UUID(),
@@ -404,11 +732,28 @@ func (cntrl *DefaultAPIController) handleRefreshToken(c *gin.Context) {
 		UserID:    token.UserID,
 		CreatedAt: time.Now().Unix(),
 		TTL:       1200,
+		Scope:     token.Scope,
+		Audience:  audience,
+		RefreshID: rtoken.ID,
+		FamilyID:  family,
+		JKT:       dpopJKT(c),
+		X5T:       authmw.CertificateThumbprint(c),
+		CodeID:    token.CodeID,
+	}
+
+	// Save new tokens to db.
+	rid, err := cntrl.db.Tokens().CreateRefresh(rtoken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":             "internal_server_error",
+			"error_description": "internal server error. Please try again later",
+		})
+		return
 	}
 
-	// Save new access token to db.
-	atokenID, err := cntrl.db.Tokens().CreateAccess(atoken)
+	atokenID, err := cntrl.createAccess(atoken)
 	if err != nil {
+		cntrl.db.Tokens().DeleteRefreshByID(rid)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":             "internal_server_error",
 			"error_description": "internal server error. Please try again later",
@@ -418,9 +763,88 @@ func (cntrl *DefaultAPIController) handleRefreshToken(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":       "success",
-		"token":         atokenID,
-		"token_type":    "bearer",
+		"access_token":  atokenID,
+		"token_type":    tokenType(c),
 		"expires_in":    1200,
-		"refresh_token": token.ID,
+		"refresh_token": rid,
+		"scope":         strings.Join(token.Scope, " "),
+	})
+}
+
+func (cntrl *DefaultAPIController) handleClientCredentials(c *gin.Context) {
+	authmw.C(cntrl.db, cntrl.config.Issuer)(c)
+	if c.IsAborted() {
+		return
+	}
+
+	// Get underlying client.
+	clientAny, ok := c.Get("client")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":             "not_found",
+			"error_description": "Client not found",
+		})
+		return
+	}
+
+	// Cast to client model.
+	client, ok := clientAny.(authdb.ClientModel)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":             "not_found",
+			"error_description": "Client not found",
+		})
+		return
+	}
+
+	// Downscope the request to the client's registered scope.
+	scope := grantScope(client, strings.Fields(tokenParam(c, "scope")))
+	if len(scope) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_scope",
+			"error_description": "none of the requested scope is registered by this client",
+		})
+		return
+	}
+
+	audience, ok := cntrl.targetAudience(splitResources(tokenParams(c, "resource")), nil)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_target",
+			"error_description": "resource is not a registered resource server",
+		})
+		return
+	}
+
+	// Create access token. The client acts on its own behalf, so the
+	// token has no associated user and no refresh token is issued.
+	// See: https://datatracker.ietf.org/doc/html/rfc6749#section-4.4
+	atoken := authdb.TokenModel{
+		ID:        common.UUID(),
+		ClientID:  client.ID,
+		UserID:    "",
+		CreatedAt: time.Now().Unix(),
+		TTL:       1200,
+		Scope:     scope,
+		Audience:  audience,
+		JKT:       dpopJKT(c),
+		X5T:       authmw.CertificateThumbprint(c),
+	}
+
+	aid, err := cntrl.createAccess(atoken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":             "internal_server_error",
+			"error_description": "internal server error. Please try again later",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "success",
+		"access_token": aid,
+		"token_type":   tokenType(c),
+		"expires_in":   1200,
+		"scope":        strings.Join(scope, " "),
 	})
 }
@@ -0,0 +1,210 @@
+package authapi
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/authmw"
+	"github.com/ufosc/OpenWebServices/pkg/common"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Example from RFC 7636, Appendix B.
+const (
+	testVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	testRedirect  = "http://127.0.0.1:8000/callback"
+)
+
+// genCodeDB returns a database holding a code issued to a public client
+// with the given challenge, and a confidential client "other" with the
+// secret "secret".
+func genCodeDB(challenge string) *testDB {
+	now := time.Now().Unix()
+	db := newTestDB()
+	db.users.users["user"] = authdb.UserModel{ID: "user"}
+	db.clients.clients["client"] = authdb.ClientModel{
+		ID:           "client",
+		ResponseType: "code",
+		RedirectURIs: []string{testRedirect},
+		Scope:        []string{"public"},
+		AuthMethod:   authmw.PublicClient,
+		CreatedAt:    now,
+		TTL:          3600,
+	}
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	db.users.users["owner"] = authdb.UserModel{ID: "owner"}
+	db.clients.clients["other"] = authdb.ClientModel{
+		ID:           "other",
+		ResponseType: "code",
+		RedirectURIs: []string{testRedirect},
+		Scope:        []string{"public"},
+		Owner:        "owner",
+		Keys:         []authdb.ClientKey{{Hash: string(hash)}},
+		CreatedAt:    now,
+		TTL:          3600,
+	}
+	db.tokens.codes["code"] = authdb.TokenModel{
+		ID:                  "code",
+		ClientID:            "client",
+		UserID:              "user",
+		RedirectURI:         testRedirect,
+		CreatedAt:           now,
+		TTL:                 300,
+		Scope:               []string{"public"},
+		CodeChallenge:       challenge,
+		CodeChallengeMethod: common.PKCES256,
+	}
+	return db
+}
+
+// redeemCode posts the code to handleAuthCode with verifier, if any, and
+// returns the response status and error code.
+func redeemCode(db *testDB, verifier string) (int, string) {
+	return redeemCodeAt(db, verifier, testRedirect)
+}
+
+// redeemCodeAt redeems the code like redeemCode, for redirectURI.
+func redeemCodeAt(db *testDB, verifier, redirectURI string) (int, string) {
+	return redeemCodeAs(db, "", verifier, redirectURI)
+}
+
+// redeemCodeAs redeems the code like redeemCodeAt, authenticating as the
+// confidential client with clientID if it is not empty.
+func redeemCodeAs(db *testDB, clientID, verifier, redirectURI string) (int, string) {
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {"code"},
+		"client_id":    {"client"},
+		"redirect_uri": {redirectURI},
+	}
+	if verifier != "" {
+		form.Set("code_verifier", verifier)
+	}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/auth/token",
+		strings.NewReader(form.Encode()))
+	c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if clientID != "" {
+		c.Request.SetBasicAuth(clientID, "secret")
+	}
+	(&DefaultAPIController{db: db}).handleAuthCode(c)
+
+	var res struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	return w.Code, res.Error
+}
+
+func TestHandleAuthCodeVerifier(t *testing.T) {
+	db := genCodeDB(testChallenge)
+	if code, err := redeemCode(db, testVerifier); code != http.StatusOK {
+		t.Fatalf("valid verifier rejected with %d %s", code, err)
+	}
+
+	if len(db.tokens.access) != 1 {
+		t.Fatalf("issued %d access tokens, expected 1", len(db.tokens.access))
+	}
+}
+
+func TestHandleAuthCodeWrongVerifier(t *testing.T) {
+	db := genCodeDB(testChallenge)
+	if _, err := redeemCode(db, testVerifier[:42]+"x"); err != "invalid_grant" {
+		t.Errorf("wrong verifier not rejected as invalid_grant: %q", err)
+	}
+
+	db = genCodeDB(testChallenge)
+	if _, err := redeemCode(db, testChallenge); err != "invalid_grant" {
+		t.Errorf("challenge as verifier not rejected as invalid_grant: %q", err)
+	}
+
+	if len(db.tokens.access) != 0 {
+		t.Fatalf("issued an access token for a wrong verifier")
+	}
+}
+
+func TestHandleAuthCodeMissingVerifier(t *testing.T) {
+	db := genCodeDB(testChallenge)
+	if _, err := redeemCode(db, ""); err != "invalid_grant" {
+		t.Fatalf("missing verifier not rejected as invalid_grant: %q", err)
+	}
+}
+
+func TestHandleAuthCodePublicWithoutChallenge(t *testing.T) {
+	db := genCodeDB("")
+	if _, err := redeemCode(db, ""); err != "invalid_grant" {
+		t.Fatalf("public client redeemed a code without PKCE: %q", err)
+	}
+}
+
+func TestHandleAuthCodeConfidentialWithoutSecret(t *testing.T) {
+	// Sending a verifier does not exempt confidential clients from
+	// authenticating.
+	db := genCodeDB(testChallenge)
+	client := db.clients.clients["client"]
+	client.AuthMethod = ""
+	db.clients.clients["client"] = client
+
+	if code, err := redeemCode(db, testVerifier); code != http.StatusUnauthorized ||
+		err != "invalid_client" {
+		t.Fatalf("expected 401 invalid_client, got %d %s", code, err)
+	}
+}
+
+func TestHandleAuthCodeOtherClient(t *testing.T) {
+	// Another client cannot redeem the code by naming its client_id.
+	db := genCodeDB(testChallenge)
+	if code, err := redeemCodeAs(db, "other", testVerifier, testRedirect); code == http.StatusOK {
+		t.Fatalf("code redeemed by another client: %d %s", code, err)
+	}
+
+	if len(db.tokens.access) != 0 {
+		t.Fatalf("issued an access token to another client")
+	}
+}
+
+func TestHandleAuthCodeVerifierWithoutChallenge(t *testing.T) {
+	// Confidential clients may skip PKCE, but must not send a verifier
+	// for a code requested without a challenge.
+	db := genCodeDB("")
+	code := db.tokens.codes["code"]
+	code.ClientID = "other"
+	db.tokens.codes["code"] = code
+
+	if _, err := redeemCodeAs(db, "other", testVerifier, testRedirect); err != "invalid_request" {
+		t.Fatalf("verifier for a code without challenge not rejected: %q", err)
+	}
+}
+
+func TestHandleAuthCodeLoopbackPort(t *testing.T) {
+	// Native apps pick a port when they request the code.
+	db := genCodeDB(testChallenge)
+	code := db.tokens.codes["code"]
+	code.RedirectURI = "http://127.0.0.1:9000/callback"
+	db.tokens.codes["code"] = code
+
+	if status, err := redeemCodeAt(db, testVerifier, code.RedirectURI); status != http.StatusOK {
+		t.Fatalf("loopback redirect on another port rejected with %d %s", status, err)
+	}
+}
+
+func TestHandleAuthCodeRedirectMismatch(t *testing.T) {
+	db := genCodeDB(testChallenge)
+	code := db.tokens.codes["code"]
+	code.RedirectURI = "http://127.0.0.1:9000/other"
+	db.tokens.codes["code"] = code
+
+	if _, err := redeemCodeAt(db, testVerifier, code.RedirectURI); err != "invalid_grant" {
+		t.Fatalf("unregistered redirect path not rejected as invalid_grant: %q", err)
+	}
+}
@@ -0,0 +1,119 @@
+package authapi
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/common"
+	"net/http"
+	"time"
+)
+
+// hasScope reports whether scope contains value.
+func hasScope(scope []string, value string) bool {
+	for _, v := range scope {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// userClaims maps a user to the OpenID Connect standard claims that a token
+// with the given scope is allowed to see.
+// See: https://openid.net/specs/openid-connect-core-1_0.html#StandardClaims
+func userClaims(scope []string, user authdb.UserModel) gin.H {
+	claims := gin.H{"sub": user.ID}
+	if hasScope(scope, "profile") {
+		claims["name"] = user.FirstName + " " + user.LastName
+		claims["given_name"] = user.FirstName
+		claims["family_name"] = user.LastName
+	}
+
+	// Users can only sign up by verifying their email address.
+	if hasScope(scope, "email") {
+		claims["email"] = user.Email
+		claims["email_verified"] = true
+	}
+
+	return claims
+}
+
+// createIDToken issues a signed ID token to client for the user that
+// authorized the given code.
+func (cntrl *DefaultAPIController) createIDToken(client authdb.ClientModel,
+	user authdb.UserModel, code authdb.TokenModel) (string, error) {
+	now := time.Now().Unix()
+	claims := userClaims(code.Scope, user)
+	claims["iss"] = cntrl.config.Issuer
+	claims["aud"] = client.ID
+	claims["iat"] = now
+	claims["exp"] = now + 1200
+	claims["auth_time"] = code.AuthTime
+	if code.Nonce != "" {
+		claims["nonce"] = code.Nonce
+	}
+
+	return cntrl.config.SigningKey.Sign("JWT", claims)
+}
+
+// OpenIDConfigurationRoute returns the OpenID Connect discovery document.
+// See: https://openid.net/specs/openid-connect-discovery-1_0.html
+func (cntrl *DefaultAPIController) OpenIDConfigurationRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		metadata := cntrl.metadata()
+		metadata["userinfo_endpoint"] = cntrl.config.Issuer + "/userinfo"
+		metadata["subject_types_supported"] = []string{"public"}
+		metadata["id_token_signing_alg_values_supported"] = []string{
+			cntrl.config.SigningKey.Alg,
+		}
+		metadata["backchannel_logout_supported"] = true
+		metadata["backchannel_logout_session_supported"] = false
+		metadata["frontchannel_logout_supported"] = true
+		metadata["frontchannel_logout_session_supported"] = false
+		metadata["claims_supported"] = []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce",
+			"name", "given_name", "family_name", "email",
+			"email_verified",
+		}
+		c.JSON(http.StatusOK, metadata)
+	}
+}
+
+// JWKSRoute returns the public keys used to verify tokens signed by the
+// server.
+func (cntrl *DefaultAPIController) JWKSRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, common.JWKSet{
+			Keys: []common.JWK{cntrl.config.SigningKey.JWK()},
+		})
+	}
+}
+
+// UserInfoRoute returns the OpenID Connect claims about the user that
+// authorized the access token.
+func (cntrl *DefaultAPIController) UserInfoRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		// Get user.
+		user, err := cntrl.currentUser(c)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":             "not_found",
+				"error_description": "user not found",
+			})
+			return
+		}
+
+		scope := grantedScope(c)
+		if !hasScope(scope, "openid") {
+			c.Header("WWW-Authenticate", "Bearer error=\"insufficient_scope\"")
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":             "insufficient_scope",
+				"error_description": "access token was not issued with the openid scope",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, userClaims(scope, user))
+	}
+}
@@ -0,0 +1,56 @@
+package authapi
+
+import (
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"testing"
+)
+
+var claimsUser = authdb.UserModel{
+	ID:        "user",
+	Email:     "gator@ufl.edu",
+	FirstName: "Albert",
+	LastName:  "Gator",
+}
+
+func TestUserClaimsOpenID(t *testing.T) {
+	claims := userClaims([]string{"openid"}, claimsUser)
+	if claims["sub"] != "user" {
+		t.Fatalf("sub claim not released")
+	}
+	for _, claim := range []string{"name", "given_name", "family_name", "email"} {
+		if _, ok := claims[claim]; ok {
+			t.Errorf("%s released without profile or email scope", claim)
+		}
+	}
+}
+
+func TestUserClaimsProfile(t *testing.T) {
+	claims := userClaims([]string{"openid", "profile"}, claimsUser)
+	if claims["name"] != "Albert Gator" || claims["given_name"] != "Albert" ||
+		claims["family_name"] != "Gator" {
+		t.Fatalf("name claims not released under profile scope: %v", claims)
+	}
+	if _, ok := claims["email"]; ok {
+		t.Fatalf("email released under profile scope")
+	}
+}
+
+func TestUserClaimsEmail(t *testing.T) {
+	claims := userClaims([]string{"openid", "email"}, claimsUser)
+	if claims["email"] != "gator@ufl.edu" || claims["email_verified"] == nil {
+		t.Fatalf("email claims not released under email scope: %v", claims)
+	}
+	if _, ok := claims["name"]; ok {
+		t.Fatalf("name released under email scope")
+	}
+}
+
+func TestUserClaimsDashboard(t *testing.T) {
+	claims := userClaims([]string{"dashboard"}, claimsUser)
+	if _, ok := claims["name"]; ok {
+		t.Errorf("name released under dashboard scope")
+	}
+	if _, ok := claims["email"]; ok {
+		t.Errorf("email released under dashboard scope")
+	}
+}
@@ -0,0 +1,203 @@
+package authapi
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/common"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// requestURIPrefix prefixes the ID of a pushed authorization request.
+// See: https://datatracker.ietf.org/doc/html/rfc9126#section-2.2
+const requestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+
+// pushedParams are the authorization request parameters that a client can
+// push.
+var pushedParams = []string{
+	"response_type", "redirect_uri", "state", "scope", "nonce",
+	"code_challenge", "code_challenge_method", "response_mode",
+}
+
+// pushedRequest finds the pushed authorization request identified by
+// requestURI. Request URIs are single use and bound to the client that
+// pushed them.
+func (cntrl *DefaultAPIController) pushedRequest(c *gin.Context, clientID,
+	requestURI string) (authdb.PushedRequestModel, bool) {
+	req, err := cntrl.db.Tokens().ConsumePushed(
+		strings.TrimPrefix(requestURI, requestURIPrefix))
+
+	if err != nil || req.ClientID != clientID ||
+		(req.CreatedAt+req.TTL) < time.Now().Unix() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_request",
+			"error_description": "request_uri expired or could not be found",
+		})
+		return authdb.PushedRequestModel{}, false
+	}
+
+	return req, true
+}
+
+// PushedAuthorizationRoute stores an authorization request pushed by an
+// authenticated client and returns the request URI that the client then
+// sends the user to the authorization endpoint with.
+// See: https://datatracker.ietf.org/doc/html/rfc9126
+func (cntrl *DefaultAPIController) PushedAuthorizationRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+
+		// Get underlying client.
+		clientAny, _ := c.Get("client")
+		client, ok := clientAny.(authdb.ClientModel)
+		if !ok {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "not_found",
+				"error_description": "Client not found",
+			})
+			return
+		}
+
+		clientID := c.PostForm("client_id")
+		if (clientID != "" && clientID != client.ID) || c.PostForm("request_uri") != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_request",
+				"error_description": "client_id does not match the authenticated client or request_uri was pushed",
+			})
+			return
+		}
+
+		params := map[string]string{}
+		for _, key := range pushedParams {
+			if value := c.PostForm(key); value != "" {
+				params[key] = value
+			}
+		}
+
+		// Validate the request now, it is validated again once the
+		// user is sent to the authorization endpoint.
+		if params["response_type"] != client.ResponseType {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_request",
+				"error_description": "response_type does not match client configuration",
+			})
+			return
+		}
+
+		if params["state"] == "" ||
+			!common.MatchRedirectURI(client.RedirectURIs, params["redirect_uri"]) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_request",
+				"error_description": "missing state or redirect_uri is not registered",
+			})
+			return
+		}
+
+		if _, ok := responseMode(params["response_type"], params["response_mode"]); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_request",
+				"error_description": "unsupported response_mode",
+			})
+			return
+		}
+
+		challenge := params["code_challenge"]
+		challengeMethod := params["code_challenge_method"]
+		if challenge != "" || challengeMethod != "" {
+			if challengeMethod == "" {
+				challengeMethod = common.PKCEPlain
+			}
+
+			if !common.ValidateCodeChallenge(challengeMethod, challenge) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":             "invalid_request",
+					"error_description": "invalid code_challenge or code_challenge_method",
+				})
+				return
+			}
+		}
+
+		// Resource indicators can be repeated, so they are stored
+		// space separated.
+		if resources := splitResources(c.PostFormArray("resource")); len(resources) > 0 {
+			if _, ok := cntrl.targetAudience(resources, nil); !ok {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":             "invalid_target",
+					"error_description": "resource is not a registered resource server",
+				})
+				return
+			}
+			params["resource"] = strings.Join(resources, " ")
+		}
+
+		if len(grantScope(client, strings.Fields(params["scope"]))) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_scope",
+				"error_description": "none of the requested scope is registered by this client",
+			})
+			return
+		}
+
+		req := authdb.PushedRequestModel{
+			ID:        common.UUID(),
+			ClientID:  client.ID,
+			Params:    params,
+			CreatedAt: time.Now().Unix(),
+			TTL:       90,
+		}
+
+		id, err := cntrl.db.Tokens().CreatePushed(req)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "Internal server error. Please try again later",
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"request_uri": requestURIPrefix + id,
+			"expires_in":  req.TTL,
+		})
+	}
+}
+
+// GetPushedRequestRoute returns public information about the client that
+// pushed an authorization request, and the scope and redirect URI that it
+// requested, for the dashboard to ask the user for consent. The request is
+// not consumed, and its scope is downscoped as it will be when granted.
+func (cntrl *DefaultAPIController) GetPushedRequestRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, err := cntrl.db.Tokens().FindPushedByID(
+			strings.TrimPrefix(c.DefaultQuery("request_uri", ""), requestURIPrefix))
+
+		if err != nil || req.ClientID != c.DefaultQuery("client_id", "") ||
+			(req.CreatedAt+req.TTL) < time.Now().Unix() {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":             "not_found",
+				"error_description": "request_uri expired or could not be found",
+			})
+			return
+		}
+
+		client, err := cntrl.db.Clients().FindByID(req.ClientID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":             "not_found",
+				"error_description": "client not found",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":       "success",
+			"id":            client.ID,
+			"name":          client.Name,
+			"description":   client.Description,
+			"response_type": client.ResponseType,
+			"redirect_uri":  req.Params["redirect_uri"],
+			"scope":         grantScope(client, strings.Fields(req.Params["scope"])),
+		})
+	}
+}
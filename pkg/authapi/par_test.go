@@ -0,0 +1,92 @@
+package authapi
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// genPushedDB returns a database holding a request "pushed" that client
+// "client" pushed for more scope than it registered.
+func genPushedDB() *testDB {
+	db := newTestDB()
+	db.clients.clients["client"] = authdb.ClientModel{
+		ID:           "client",
+		Name:         "client",
+		ResponseType: "code",
+		Scope:        []string{"public", "email", "profile"},
+	}
+	db.tokens.pushed["pushed"] = authdb.PushedRequestModel{
+		ID:       "pushed",
+		ClientID: "client",
+		Params: map[string]string{
+			"redirect_uri": testRedirect,
+			"scope":        "email modify",
+		},
+		CreatedAt: time.Now().Unix(),
+		TTL:       90,
+	}
+	return db
+}
+
+// getPushed looks up requestURI for clientID and returns the response
+// status and body.
+func getPushed(db *testDB, clientID, requestURI string) (int, map[string]any) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/auth/par?"+url.Values{
+		"client_id":   {clientID},
+		"request_uri": {requestURI},
+	}.Encode(), nil)
+	(&DefaultAPIController{db: db}).GetPushedRequestRoute()(c)
+
+	res := map[string]any{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	return w.Code, res
+}
+
+func TestGetPushedRequest(t *testing.T) {
+	db := genPushedDB()
+	code, res := getPushed(db, "client", requestURIPrefix+"pushed")
+	if code != http.StatusOK {
+		t.Fatalf("pushed request not found: %d %v", code, res)
+	}
+
+	// Only the pushed scope that the client registered is displayed.
+	scope, _ := res["scope"].([]any)
+	if len(scope) != 1 || scope[0] != "email" {
+		t.Errorf("expected scope [email], got %v", res["scope"])
+	}
+
+	if res["redirect_uri"] != testRedirect {
+		t.Errorf("expected redirect_uri %s, got %v", testRedirect, res["redirect_uri"])
+	}
+
+	if _, ok := db.tokens.pushed["pushed"]; !ok {
+		t.Errorf("pushed request consumed by lookup")
+	}
+}
+
+func TestGetPushedRequestOtherClient(t *testing.T) {
+	db := genPushedDB()
+	if code, _ := getPushed(db, "other", requestURIPrefix+"pushed"); code != http.StatusNotFound {
+		t.Fatalf("pushed request found for another client: %d", code)
+	}
+}
+
+func TestGetPushedRequestExpired(t *testing.T) {
+	db := genPushedDB()
+	req := db.tokens.pushed["pushed"]
+	req.CreatedAt -= 120
+	db.tokens.pushed["pushed"] = req
+
+	if code, _ := getPushed(db, "client", requestURIPrefix+"pushed"); code != http.StatusNotFound {
+		t.Fatalf("expired pushed request found: %d", code)
+	}
+}
@@ -0,0 +1,611 @@
+package authapi
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/authmw"
+	"github.com/ufosc/OpenWebServices/pkg/common"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// clientGrantTypes maps each response type to the grant types that its
+// clients may use.
+var clientGrantTypes = map[string][]string{
+	"code": {
+		"authorization_code", "refresh_token",
+		"client_credentials", deviceCodeGrant,
+		tokenExchangeGrant,
+	},
+	"token": {"implicit"},
+}
+
+// newClientKey generates a random client secret. Returns the secret and
+// its bcrypt hash.
+func newClientKey() (string, string, error) {
+	privateKey, _, _, err := elliptic.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+
+	pkey := base64.StdEncoding.EncodeToString(privateKey)
+	hash, err := bcrypt.GenerateFromPassword([]byte(pkey), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", err
+	}
+
+	return pkey, string(hash), nil
+}
+
+// validateClient checks the metadata of a client before it is saved.
+// Returns an RFC 7591 error code and description if it is invalid.
+func (cntrl *DefaultAPIController) validateClient(client authdb.ClientModel) (
+	string, string) {
+	if !common.ValidateResponseType(client.ResponseType) {
+		return "invalid_client_metadata",
+			"response_type must be 'code' or 'token'"
+	}
+
+	if len(client.RedirectURIs) == 0 || len(client.RedirectURIs) > 10 {
+		return "invalid_redirect_uri",
+			"between 1 and 10 redirect_uris are required"
+	}
+
+	for _, uri := range client.RedirectURIs {
+		if !common.ValidateRedirectURI(uri) {
+			return "invalid_redirect_uri", "invalid redirect_uri " + uri
+		}
+	}
+
+	// The introspection scope is not a scope that users grant. Only
+	// CreateClientRoute accepts it, from holders of the
+	// clients.introspect realm.
+	scope := []string{}
+	for _, value := range client.Scope {
+		if value != introspectionScope {
+			scope = append(scope, value)
+		}
+	}
+
+	if !common.ValidateScope(client.ResponseType, scope) {
+		return "invalid_client_metadata", "invalid or unknown scope"
+	}
+
+	if client.FrontchannelLogoutURI != "" && !validLogoutURI(client.FrontchannelLogoutURI) {
+		return "invalid_client_metadata",
+			"logout URIs must be https URLs without a fragment"
+	}
+
+	if client.BackchannelLogoutURI != "" &&
+		!validBackchannelLogoutURI(client.BackchannelLogoutURI) {
+		return "invalid_client_metadata",
+			"backchannel_logout_uri must be a public https URL without a fragment"
+	}
+
+	// Ensure name and description are not too long.
+	if client.Name == "" || len(client.Name) > 12 {
+		return "invalid_client_metadata",
+			"name must be between 1 and 12 characters"
+	}
+
+	if len(client.Description) > 150 {
+		return "invalid_client_metadata",
+			"description cannot be longer than 150 characters"
+	}
+
+	// Ensure name doesn't already belong to another client.
+	existing, err := cntrl.db.Clients().FindByName(client.Name)
+	if err != mongo.ErrNoDocuments && (err != nil || existing.ID != client.ID) {
+		return "invalid_client_metadata", "client name already registered"
+	}
+
+	return "", ""
+}
+
+// bearerToken returns the bearer token in the Authorization header, or an
+// empty string if there is none.
+func bearerToken(c *gin.Context) string {
+	auth := strings.Split(c.GetHeader("Authorization"), " ")
+	if len(auth) != 2 || auth[0] != "Bearer" {
+		return ""
+	}
+	return auth[1]
+}
+
+// registrationRequest is the client metadata accepted by the registration
+// routes.
+// See: https://datatracker.ietf.org/doc/html/rfc7591#section-2
+type registrationRequest struct {
+	ClientID                string         `json:"client_id"`
+	ClientName              string         `json:"client_name"`
+	Description             string         `json:"description"`
+	RedirectURIs            []string       `json:"redirect_uris"`
+	ResponseTypes           []string       `json:"response_types"`
+	GrantTypes              []string       `json:"grant_types"`
+	Scope                   string         `json:"scope"`
+	TokenEndpointAuthMethod string         `json:"token_endpoint_auth_method"`
+	JWKS                    *common.JWKSet `json:"jwks"`
+	JWKSURI                 string         `json:"jwks_uri"`
+	TLSClientAuthSubjectDN  string         `json:"tls_client_auth_subject_dn"`
+	TLSClientAuthSANDNS     string         `json:"tls_client_auth_san_dns"`
+	RequirePAR              bool           `json:"require_pushed_authorization_requests"`
+	BackchannelLogoutURI    string         `json:"backchannel_logout_uri"`
+	FrontchannelLogoutURI   string         `json:"frontchannel_logout_uri"`
+}
+
+// apply copies the request metadata to client. methods are the client
+// authentication methods that can be registered. Returns an RFC 7591 error
+// code and description if the metadata is not supported.
+func (req registrationRequest) apply(client *authdb.ClientModel,
+	methods []string) (string, string) {
+	if len(req.ResponseTypes) > 1 {
+		return "invalid_client_metadata",
+			"only one response_type can be registered"
+	}
+
+	client.ResponseType = "code"
+	if len(req.ResponseTypes) == 1 {
+		client.ResponseType = req.ResponseTypes[0]
+	}
+
+	for _, grantType := range req.GrantTypes {
+		if !hasScope(clientGrantTypes[client.ResponseType], grantType) {
+			return "invalid_client_metadata",
+				"grant_type " + grantType + " cannot be used with this response_type"
+		}
+	}
+
+	if code, desc := req.applyKeys(client, methods); code != "" {
+		return code, desc
+	}
+
+	client.Scope = strings.Fields(req.Scope)
+	if len(client.Scope) == 0 {
+		client.Scope = []string{"public"}
+	}
+
+	if hasScope(client.Scope, introspectionScope) {
+		return "invalid_client_metadata",
+			"the introspection scope cannot be registered dynamically"
+	}
+
+	client.Name = req.ClientName
+	client.Description = req.Description
+	client.RedirectURIs = req.RedirectURIs
+	client.RequirePAR = req.RequirePAR
+	client.BackchannelLogoutURI = req.BackchannelLogoutURI
+	client.FrontchannelLogoutURI = req.FrontchannelLogoutURI
+	return "", ""
+}
+
+// applyKeys copies the authentication method, keys and certificate subject
+// of the request to client, if the method is "none" or one of methods.
+// Clients using private_key_jwt or self_signed_tls_client_auth must register
+// exactly one of a key set or an https key set URI, and clients using
+// tls_client_auth exactly one of a subject DN or DNS name.
+func (req registrationRequest) applyKeys(client *authdb.ClientModel,
+	methods []string) (string, string) {
+	methods = append([]string{authmw.PublicClient}, methods...)
+	if req.TokenEndpointAuthMethod != "" && !hasScope(methods, req.TokenEndpointAuthMethod) {
+		return "invalid_client_metadata",
+			"token_endpoint_auth_method must be one of " + strings.Join(methods, ", ")
+	}
+
+	client.AuthMethod = req.TokenEndpointAuthMethod
+	client.JWKS = ""
+	client.JWKSURI = ""
+	client.TLSClientAuthSubjectDN = ""
+	client.TLSClientAuthSANDNS = ""
+
+	subject := req.TLSClientAuthSubjectDN != "" || req.TLSClientAuthSANDNS != ""
+	if subject && req.TokenEndpointAuthMethod != authmw.TLSClientAuth {
+		return "invalid_client_metadata",
+			"certificate subjects require token_endpoint_auth_method 'tls_client_auth'"
+	}
+
+	switch req.TokenEndpointAuthMethod {
+	case "", "client_secret_basic", "client_secret_post", authmw.TLSClientAuth,
+		authmw.PublicClient:
+		// Secrets, CA-issued certificates and public clients need no
+		// keys.
+		if req.JWKS != nil || req.JWKSURI != "" {
+			return "invalid_client_metadata",
+				"jwks and jwks_uri require token_endpoint_auth_method 'private_key_jwt' or 'self_signed_tls_client_auth'"
+		}
+
+		if req.TokenEndpointAuthMethod != authmw.TLSClientAuth {
+			return "", ""
+		}
+
+		if (req.TLSClientAuthSubjectDN == "") == (req.TLSClientAuthSANDNS == "") {
+			return "invalid_client_metadata",
+				"exactly one of tls_client_auth_subject_dn or tls_client_auth_san_dns is required"
+		}
+
+		client.TLSClientAuthSubjectDN = req.TLSClientAuthSubjectDN
+		client.TLSClientAuthSANDNS = req.TLSClientAuthSANDNS
+		return "", ""
+	case authmw.PrivateKeyJWT, authmw.SelfSignedTLSClientAuth:
+	default:
+		return "invalid_client_metadata",
+			"token_endpoint_auth_method must be one of " + strings.Join(methods, ", ")
+	}
+
+	if (req.JWKS == nil) == (req.JWKSURI == "") {
+		return "invalid_client_metadata",
+			"exactly one of jwks or jwks_uri is required"
+	}
+
+	if req.JWKSURI != "" {
+		if !authmw.ValidJWKSURI(req.JWKSURI) {
+			return "invalid_client_metadata",
+				"jwks_uri must be an https URL of a public host"
+		}
+		client.JWKSURI = req.JWKSURI
+		return "", ""
+	}
+
+	if len(req.JWKS.Keys) == 0 {
+		return "invalid_client_metadata", "jwks must contain at least one key"
+	}
+
+	for _, key := range req.JWKS.Keys {
+		if _, err := key.PublicKey(); err != nil {
+			return "invalid_client_metadata", "jwks contains an unsupported key"
+		}
+	}
+
+	jwks, err := json.Marshal(req.JWKS)
+	if err != nil {
+		return "invalid_client_metadata", "malformed jwks"
+	}
+
+	client.JWKS = string(jwks)
+	return "", ""
+}
+
+// registrationResponse returns the registered metadata of client.
+// See: https://datatracker.ietf.org/doc/html/rfc7591#section-3.2.1
+func (cntrl *DefaultAPIController) registrationResponse(
+	client authdb.ClientModel) gin.H {
+	authMethod := client.AuthMethod
+	if authMethod == "" {
+		authMethod = "client_secret_basic"
+	}
+
+	res := gin.H{
+		"client_id":                  client.ID,
+		"client_name":                client.Name,
+		"description":                client.Description,
+		"redirect_uris":              client.RedirectURIs,
+		"response_types":             []string{client.ResponseType},
+		"grant_types":                clientGrantTypes[client.ResponseType],
+		"scope":                      strings.Join(client.Scope, " "),
+		"token_endpoint_auth_method": authMethod,
+		"client_id_issued_at":        client.CreatedAt,
+		"client_secret_expires_at":   client.CreatedAt + client.TTL,
+		"registration_client_uri":    cntrl.config.Issuer + "/register/" + client.ID,
+
+		"require_pushed_authorization_requests": client.RequirePAR,
+	}
+
+	if client.JWKSURI != "" {
+		res["jwks_uri"] = client.JWKSURI
+	}
+
+	if client.TLSClientAuthSubjectDN != "" {
+		res["tls_client_auth_subject_dn"] = client.TLSClientAuthSubjectDN
+	}
+
+	if client.TLSClientAuthSANDNS != "" {
+		res["tls_client_auth_san_dns"] = client.TLSClientAuthSANDNS
+	}
+
+	// Tokens issued to clients that authenticate with a certificate
+	// are bound to it.
+	if authMethod == authmw.TLSClientAuth || authMethod == authmw.SelfSignedTLSClientAuth {
+		res["tls_client_certificate_bound_access_tokens"] = true
+	}
+
+	if client.BackchannelLogoutURI != "" {
+		res["backchannel_logout_uri"] = client.BackchannelLogoutURI
+		res["backchannel_logout_session_required"] = false
+	}
+
+	if client.FrontchannelLogoutURI != "" {
+		res["frontchannel_logout_uri"] = client.FrontchannelLogoutURI
+		res["frontchannel_logout_session_required"] = false
+	}
+
+	var jwks common.JWKSet
+	if client.JWKS != "" && json.Unmarshal([]byte(client.JWKS), &jwks) == nil {
+		res["jwks"] = jwks
+	}
+
+	return res
+}
+
+// CreateInitialTokenRoute issues an initial access token that authorizes
+// the dynamic registration of one client within an hour. The client is
+// owned by the user that requested it.
+func (cntrl *DefaultAPIController) CreateInitialTokenRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		// Get underlying user (from middleware).
+		userAny, _ := c.Get("user")
+		user, _ := userAny.(authdb.UserModel)
+
+		token := authdb.TokenModel{
+			ID:        common.UUID(),
+			ClientID:  authmw.DashboardClient.ID,
+			UserID:    user.ID,
+			CreatedAt: time.Now().Unix(),
+			TTL:       3600,
+		}
+
+		id, err := cntrl.db.Tokens().CreateInitial(token)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "Internal server error. Please try again later",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":              "success",
+			"initial_access_token": id,
+			"expires_in":           token.TTL,
+		})
+	}
+}
+
+// RegisterClientRoute registers a client using an initial access token,
+// which is consumed once the client is registered.
+// See: https://datatracker.ietf.org/doc/html/rfc7591#section-3
+func (cntrl *DefaultAPIController) RegisterClientRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+
+		// Verify initial access token.
+		initial, err := cntrl.db.Tokens().FindInitialByID(bearerToken(c))
+		if err != nil || (initial.CreatedAt+initial.TTL) < time.Now().Unix() {
+			c.Header("WWW-Authenticate", "Bearer error=\"invalid_token\"")
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":             "invalid_token",
+				"error_description": "initial access token expired or could not be found",
+			})
+			return
+		}
+
+		// Ensure the user that issued the token still exists.
+		if _, err := cntrl.db.Users().FindByID(initial.UserID); err != nil {
+			cntrl.db.Tokens().DeleteInitialByID(initial.ID)
+			c.Header("WWW-Authenticate", "Bearer error=\"invalid_token\"")
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":             "invalid_token",
+				"error_description": "initial access token expired or could not be found",
+			})
+			return
+		}
+
+		var req registrationRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_client_metadata",
+				"error_description": "malformed client metadata",
+			})
+			return
+		}
+
+		client := authdb.ClientModel{
+			Owner:     initial.UserID,
+			CreatedAt: time.Now().Unix(),
+			TTL:       7890000, // 3 months.
+		}
+
+		code, desc := req.apply(&client, cntrl.clientAuthMethods())
+		if code == "" {
+			code, desc = cntrl.validateClient(client)
+		}
+
+		if code != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             code,
+				"error_description": desc,
+			})
+			return
+		}
+
+		// Generate client secret and registration access token.
+		pkey, pkeyHash, err := newClientKey()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "Internal server error. Please try again later",
+			})
+			return
+		}
+
+		rkey, rkeyHash, err := newClientKey()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "Internal server error. Please try again later",
+			})
+			return
+		}
+
+		// Consume the initial access token, unless another request
+		// used it while this one was validated.
+		if _, err := cntrl.db.Tokens().ConsumeInitial(initial.ID); err != nil {
+			c.Header("WWW-Authenticate", "Bearer error=\"invalid_token\"")
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":             "invalid_token",
+				"error_description": "initial access token expired or could not be found",
+			})
+			return
+		}
+
+		client.Keys = []authdb.ClientKey{{Hash: pkeyHash, CreatedAt: client.CreatedAt}}
+		client.RegistrationKey = rkeyHash
+		client.ID, err = cntrl.db.Clients().Create(client)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "Internal server error. Please try again later",
+			})
+			return
+		}
+
+		// Public clients and clients using keys or certificates never
+		// use their secret.
+		res := cntrl.registrationResponse(client)
+		if authmw.UsesSecret(client) {
+			res["client_secret"] = pkey
+		}
+		res["registration_access_token"] = rkey
+		c.JSON(http.StatusCreated, res)
+	}
+}
+
+// registeredClient authenticates a request to the client configuration
+// routes using the client's registration access token.
+func (cntrl *DefaultAPIController) registeredClient(c *gin.Context) (
+	authdb.ClientModel, bool) {
+	c.Header("Cache-Control", "no-store")
+	client, err := cntrl.db.Clients().FindByID(c.Param("id"))
+	if err != nil || client.RegistrationKey == "" ||
+		!common.VerifyPassword(client.RegistrationKey, bearerToken(c)) {
+		c.Header("WWW-Authenticate", "Bearer error=\"invalid_token\"")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":             "invalid_token",
+			"error_description": "invalid registration access token",
+		})
+		return authdb.ClientModel{}, false
+	}
+
+	return client, true
+}
+
+// GetRegistrationRoute returns the registered metadata of a client.
+// See: https://datatracker.ietf.org/doc/html/rfc7592#section-2.1
+func (cntrl *DefaultAPIController) GetRegistrationRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client, ok := cntrl.registeredClient(c)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, cntrl.registrationResponse(client))
+	}
+}
+
+// UpdateRegistrationRoute replaces the registered metadata of a client.
+// Clients that switch to authenticating with a secret are issued a new one,
+// as they never received the secret generated when they were registered.
+// See: https://datatracker.ietf.org/doc/html/rfc7592#section-2.2
+func (cntrl *DefaultAPIController) UpdateRegistrationRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client, ok := cntrl.registeredClient(c)
+		if !ok {
+			return
+		}
+
+		var req registrationRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_client_metadata",
+				"error_description": "malformed client metadata",
+			})
+			return
+		}
+
+		if req.ClientID != client.ID {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_request",
+				"error_description": "client_id does not match this registration",
+			})
+			return
+		}
+
+		usedSecret := authmw.UsesSecret(client)
+		code, desc := req.apply(&client, cntrl.clientAuthMethods())
+		if code == "" {
+			code, desc = cntrl.validateClient(client)
+		}
+
+		if code != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             code,
+				"error_description": desc,
+			})
+			return
+		}
+
+		pkey := ""
+		if !usedSecret && authmw.UsesSecret(client) {
+			key, keyHash, err := newClientKey()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":             "internal_server_error",
+					"error_description": "Internal server error. Please try again later",
+				})
+				return
+			}
+			pkey = key
+			client.Keys = []authdb.ClientKey{{Hash: keyHash, CreatedAt: time.Now().Unix()}}
+		}
+
+		if _, err := cntrl.db.Clients().Update(client); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "Internal server error. Please try again later",
+			})
+			return
+		}
+
+		res := cntrl.registrationResponse(client)
+		if pkey != "" {
+			res["client_secret"] = pkey
+		}
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+// DeleteRegistrationRoute deletes a dynamically registered client.
+// See: https://datatracker.ietf.org/doc/html/rfc7592#section-2.3
+func (cntrl *DefaultAPIController) DeleteRegistrationRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client, ok := cntrl.registeredClient(c)
+		if !ok {
+			return
+		}
+
+		// Revoke the client's tokens along with it.
+		if err := cntrl.db.Tokens().DeleteByClient(client.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "could not delete client at this time, please try again later",
+			})
+			return
+		}
+
+		if err := cntrl.db.Clients().DeleteByID(client.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "could not delete client at this time, please try again later",
+			})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,222 @@
+package authapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/authmw"
+	"github.com/ufosc/OpenWebServices/pkg/common"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// certificateMethods are the client authentication methods offered when
+// client certificates are configured.
+var certificateMethods = (&DefaultAPIController{
+	config: Config{ClientCertificates: true},
+}).clientAuthMethods()
+
+func TestApplyKeysTLSClientAuth(t *testing.T) {
+	var client authdb.ClientModel
+	req := registrationRequest{
+		TokenEndpointAuthMethod: "tls_client_auth",
+		TLSClientAuthSubjectDN:  "CN=client",
+	}
+
+	if code, desc := req.applyKeys(&client, certificateMethods); code != "" {
+		t.Fatalf("tls_client_auth rejected: %s", desc)
+	}
+
+	if client.TLSClientAuthSubjectDN != "CN=client" {
+		t.Fatalf("certificate subject not registered")
+	}
+}
+
+func TestApplyKeysSelfSignedTLSClientAuth(t *testing.T) {
+	key, err := common.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var client authdb.ClientModel
+	req := registrationRequest{
+		TokenEndpointAuthMethod: "self_signed_tls_client_auth",
+		JWKS:                    &common.JWKSet{Keys: []common.JWK{key.JWK()}},
+	}
+
+	if code, desc := req.applyKeys(&client, certificateMethods); code != "" {
+		t.Fatalf("self_signed_tls_client_auth rejected: %s", desc)
+	}
+}
+
+func TestApplyKeysWithoutCertificates(t *testing.T) {
+	methods := (&DefaultAPIController{}).clientAuthMethods()
+	for _, method := range []string{"tls_client_auth", "self_signed_tls_client_auth"} {
+		var client authdb.ClientModel
+		req := registrationRequest{
+			TokenEndpointAuthMethod: method,
+			TLSClientAuthSubjectDN:  "CN=client",
+		}
+
+		if code, _ := req.applyKeys(&client, methods); code == "" {
+			t.Errorf("%s registered without client certificates configured", method)
+		}
+	}
+}
+
+func TestMetadataAuthMethods(t *testing.T) {
+	var metadata struct {
+		Methods []string `json:"token_endpoint_auth_methods_supported"`
+		Bound   bool     `json:"tls_client_certificate_bound_access_tokens"`
+	}
+
+	raw, _ := json.Marshal((&DefaultAPIController{}).metadata())
+	json.Unmarshal(raw, &metadata)
+	if hasScope(metadata.Methods, "tls_client_auth") || metadata.Bound {
+		t.Fatalf("certificate authentication advertised without certificates")
+	}
+
+	cntrl := &DefaultAPIController{config: Config{ClientCertificates: true}}
+	raw, _ = json.Marshal(cntrl.metadata())
+	json.Unmarshal(raw, &metadata)
+	if !hasScope(metadata.Methods, "tls_client_auth") || !metadata.Bound {
+		t.Fatalf("certificate authentication not advertised: %v", metadata.Methods)
+	}
+}
+
+// genRegistrationDB returns a database holding an initial access token
+// "initial" issued to user "user", and a client "client" that signs client
+// assertions and has the registration access token "registration".
+func genRegistrationDB() *testDB {
+	now := time.Now().Unix()
+	db := newTestDB()
+	db.users.users["user"] = authdb.UserModel{ID: "user"}
+	db.tokens.initial["initial"] = authdb.TokenModel{
+		ID:        "initial",
+		UserID:    "user",
+		CreatedAt: now,
+		TTL:       3600,
+	}
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("registration"), bcrypt.MinCost)
+	db.clients.clients["client"] = authdb.ClientModel{
+		ID:              "client",
+		Name:            "client",
+		ResponseType:    "code",
+		RedirectURIs:    []string{testRedirect},
+		Scope:           []string{"public"},
+		AuthMethod:      authmw.PrivateKeyJWT,
+		JWKSURI:         "https://app.example.com/jwks.json",
+		RegistrationKey: string(hash),
+		CreatedAt:       now,
+		TTL:             3600,
+	}
+	return db
+}
+
+// sendRegistration sends req to handler with the bearer token and returns
+// the response status and body.
+func sendRegistration(handler gin.HandlerFunc, method, id, token string,
+	req registrationRequest) (int, map[string]any) {
+	body, _ := json.Marshal(req)
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, "/register", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Request.Header.Set("Authorization", "Bearer "+token)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	handler(c)
+
+	res := map[string]any{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	return w.Code, res
+}
+
+func TestRegisterClientInitialTokenSingleUse(t *testing.T) {
+	db := genRegistrationDB()
+	route := (&DefaultAPIController{db: db}).RegisterClientRoute()
+	req := registrationRequest{ClientName: "first", RedirectURIs: []string{testRedirect}}
+	if code, res := sendRegistration(route, http.MethodPost, "", "initial", req); code != http.StatusCreated {
+		t.Fatalf("registration failed: %d %v", code, res)
+	}
+
+	req.ClientName = "second"
+	if code, _ := sendRegistration(route, http.MethodPost, "", "initial", req); code != http.StatusUnauthorized {
+		t.Fatalf("initial access token registered a second client: %d", code)
+	}
+
+	if _, ok := db.clients.clients["second"]; ok {
+		t.Errorf("second client was registered")
+	}
+}
+
+func TestRegisterClientInvalidMetadataKeepsToken(t *testing.T) {
+	// Rejected registrations do not use up the initial access token.
+	db := genRegistrationDB()
+	route := (&DefaultAPIController{db: db}).RegisterClientRoute()
+	req := registrationRequest{ClientName: "first"}
+	if code, _ := sendRegistration(route, http.MethodPost, "", "initial", req); code != http.StatusBadRequest {
+		t.Fatalf("registration without redirect_uris not rejected: %d", code)
+	}
+
+	if _, ok := db.tokens.initial["initial"]; !ok {
+		t.Fatalf("initial access token consumed by a rejected registration")
+	}
+}
+
+func TestUpdateRegistrationSecretMethod(t *testing.T) {
+	// A client that stops signing assertions needs a secret it received.
+	db := genRegistrationDB()
+	route := (&DefaultAPIController{db: db}).UpdateRegistrationRoute()
+	req := registrationRequest{
+		ClientID:                "client",
+		ClientName:              "client",
+		RedirectURIs:            []string{testRedirect},
+		TokenEndpointAuthMethod: "client_secret_basic",
+	}
+
+	code, res := sendRegistration(route, http.MethodPut, "client", "registration", req)
+	if code != http.StatusOK {
+		t.Fatalf("update failed: %d %v", code, res)
+	}
+
+	secret, _ := res["client_secret"].(string)
+	if secret == "" {
+		t.Fatalf("no client_secret returned when switching to client_secret_basic")
+	}
+
+	keys := db.clients.clients["client"].ActiveKeys(time.Now().Unix())
+	if len(keys) != 1 || !common.VerifyPassword(keys[0].Hash, secret) {
+		t.Fatalf("returned client_secret is not stored")
+	}
+}
+
+func TestUpdateRegistrationKeepsSecret(t *testing.T) {
+	db := genRegistrationDB()
+	client := db.clients.clients["client"]
+	client.AuthMethod = "client_secret_post"
+	client.JWKSURI = ""
+	db.clients.clients["client"] = client
+
+	route := (&DefaultAPIController{db: db}).UpdateRegistrationRoute()
+	req := registrationRequest{
+		ClientID:                "client",
+		ClientName:              "client",
+		RedirectURIs:            []string{testRedirect},
+		TokenEndpointAuthMethod: "client_secret_basic",
+	}
+
+	code, res := sendRegistration(route, http.MethodPut, "client", "registration", req)
+	if code != http.StatusOK {
+		t.Fatalf("update failed: %d %v", code, res)
+	}
+
+	if _, ok := res["client_secret"]; ok {
+		t.Fatalf("client_secret rotated by an update between secret methods")
+	}
+}
@@ -1,36 +1,33 @@
 package authapi
 
 import (
-	"crypto/elliptic"
-	"crypto/rand"
-	"encoding/base64"
 	"github.com/gin-gonic/gin"
 	"github.com/ufosc/OpenWebServices/pkg/authdb"
-	"github.com/ufosc/OpenWebServices/pkg/common"
-	"go.mongodb.org/mongo-driver/mongo"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/ufosc/OpenWebServices/pkg/authmw"
 	"net/http"
 	"strconv"
 	"time"
 )
 
 // GetUserRoute returns user information based on the permissions
-// defined by the client's scope.
+// defined by the token's scope.
 func (cntrl *DefaultAPIController) GetUserRoute() gin.HandlerFunc {
 	return func(c *gin.Context) {
 
-		// Get client.
-		clientAny, _ := c.Get("client")
-		client, _ := clientAny.(authdb.ClientModel)
-
 		// Get user.
-		userAny, _ := c.Get("user")
-		user, _ := userAny.(authdb.UserModel)
+		user, err := cntrl.currentUser(c)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":             "not_found",
+				"error_description": "user not found",
+			})
+			return
+		}
 
 		// Currently, "email" is the highest level of privilege.
 		// We also want to allow "dashboard" full access.
 		hasEmailScope := false
-		for _, scope := range client.Scope {
+		for _, scope := range grantedScope(c) {
 			if scope == "email" || scope == "dashboard" {
 				hasEmailScope = true
 				break
@@ -69,8 +66,14 @@ func (cntrl *DefaultAPIController) UpdateUserRoute() gin.HandlerFunc {
 		}
 
 		// Get user.
-		userAny, _ := c.Get("user")
-		user, _ := userAny.(authdb.UserModel)
+		user, err := cntrl.currentUser(c)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":             "not_found",
+				"error_description": "user not found",
+			})
+			return
+		}
 
 		// Extract JSON body.
 		if err := c.BindJSON(&req); err != nil {
@@ -273,8 +276,9 @@ func (cntrl *DefaultAPIController) GetClientRoute() gin.HandlerFunc {
 			"name":          clientExists.Name,
 			"description":   clientExists.Description,
 			"response_type": clientExists.ResponseType,
-			"redirect_uri":  clientExists.RedirectURI,
+			"redirect_uris": clientExists.RedirectURIs,
 			"scope":         clientExists.Scope,
+			"require_par":   clientExists.RequirePAR,
 		})
 	}
 }
@@ -289,8 +293,17 @@ func (cntrl *DefaultAPIController) CreateClientRoute() gin.HandlerFunc {
 			Name         string   `json:"name" binding:"required"`
 			Description  string   `json:"description" binding:"required"`
 			ResponseType string   `json:"response_type" binding:"required"`
-			RedirectURI  string   `json:"redirect_uri" binding:"required"`
+			RedirectURI  string   `json:"redirect_uri"`
+			RedirectURIs []string `json:"redirect_uris"`
 			Scope        []string `json:"scope" binding:"required"`
+			RequirePAR   bool     `json:"require_par"`
+
+			// Public clients, such as native and browser apps, are
+			// issued no secret and must use PKCE.
+			Public bool `json:"public"`
+
+			BackchannelLogoutURI  string `json:"backchannel_logout_uri"`
+			FrontchannelLogoutURI string `json:"frontchannel_logout_uri"`
 		}
 
 		// Extract JSON body.
@@ -323,75 +336,148 @@ func (cntrl *DefaultAPIController) CreateClientRoute() gin.HandlerFunc {
 			return
 		}
 
-		// Validate response type.
-		if req.ResponseType != "code" && req.ResponseType != "token" {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":             "invalid_request",
-				"error_description": "response_type must be 'code' or 'token'",
+		// Clients that can introspect any token require the
+		// introspection realm, which only administrators grant.
+		if hasScope(req.Scope, introspectionScope) &&
+			!hasScope(user.Realms, "clients.introspect") {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":             "unauthorized",
+				"error_description": "User not authorized to create introspection clients",
 			})
 			return
 		}
 
-		// Validate redirect uri.
-		if !common.ValidateRedirectURI(req.RedirectURI) {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":             "invalid_request",
-				"error_description": "invalid redirect_uri",
-			})
-			return
+		// Validate client metadata. A single redirect_uri is accepted
+		// for compatibility with older clients of this API.
+		if req.RedirectURI != "" {
+			req.RedirectURIs = append(req.RedirectURIs, req.RedirectURI)
 		}
 
-		// Validate scope.
-		if !common.ValidateScope(req.ResponseType, req.Scope) {
+		client := authdb.ClientModel{
+			ID:           "",
+			Name:         req.Name,
+			Description:  req.Description,
+			ResponseType: req.ResponseType,
+			RedirectURIs: req.RedirectURIs,
+			Scope:        req.Scope,
+			Owner:        user.ID,
+			CreatedAt:    time.Now().Unix(),
+			TTL:          7890000, // 3 months.
+			RequirePAR:   req.RequirePAR,
+
+			BackchannelLogoutURI:  req.BackchannelLogoutURI,
+			FrontchannelLogoutURI: req.FrontchannelLogoutURI,
+		}
+
+		if req.Public {
+			client.AuthMethod = authmw.PublicClient
+		}
+
+		if _, desc := cntrl.validateClient(client); desc != "" {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error":             "invalid_request",
-				"error_description": "invalid or unknown scope",
+				"error_description": desc,
 			})
 			return
 		}
 
-		// Ensure name and description are not too long.
-		if len(req.Name) > 12 {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":             "invalid_request",
-				"error_description": "name cannot be longer than 12 characters",
+		// Generate random key.
+		pkey, pkeyHash, err := newClientKey()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "Internal server error. Please try again later",
 			})
 			return
 		}
 
-		if len(req.Description) > 150 {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":             "invalid_request",
-				"error_description": "description cannot be longer than 150 characters",
+		client.Keys = []authdb.ClientKey{{Hash: pkeyHash, CreatedAt: client.CreatedAt}}
+		id, err := cntrl.db.Clients().Create(client)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "internal server error",
 			})
 			return
 		}
 
-		// Ensure name doesn't already exist.
-		if _, err := cntrl.db.Clients().FindByName(req.Name); err != mongo.ErrNoDocuments {
+		res := gin.H{
+			"message": "success",
+			"id":      id,
+		}
+
+		if !req.Public {
+			res["pkey"] = pkey
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+// RotateClientSecretRoute returns the gin middleware for generating a new
+// client secret. The previous secrets remain valid for a grace period
+// (one day by default, at most 30 days) so that the client can be
+// redeployed with the new secret without downtime.
+func (cntrl *DefaultAPIController) RotateClientSecretRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		// Request body. Optional.
+		var req struct {
+			GracePeriod *int64 `json:"grace_period"`
+		}
+
+		if c.Request.ContentLength > 0 {
+			if err := c.BindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":             "invalid_request",
+					"error_description": "malformed request body",
+				})
+				return
+			}
+		}
+
+		grace := int64(86400)
+		if req.GracePeriod != nil {
+			grace = *req.GracePeriod
+		}
+
+		if grace < 0 || grace > 2592000 {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error":             "invalid_request",
-				"error_description": "client name already registered",
+				"error_description": "grace_period must be between 0 and 2592000 seconds",
 			})
 			return
 		}
 
-		// Generate random key.
-		privateKey, _, _, err := elliptic.GenerateKey(elliptic.P256(), rand.Reader)
+		// Get underlying user (from middleware).
+		userAny, _ := c.Get("user")
+		user, _ := userAny.(authdb.UserModel)
+
+		client, err := cntrl.db.Clients().FindByID(c.Param("id"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":             "internal_server_error",
-				"error_description": "Internal server error. Please try again later",
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":             "not_found",
+				"error_description": "client not found",
 			})
 			return
 		}
 
-		pkey := base64.StdEncoding.EncodeToString(privateKey)
+		if client.Owner != user.ID {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":             "unauthorized",
+				"error_description": "client does not belong to you",
+			})
+			return
+		}
 
-		// Hash random key.
-		pkeyHash, err := bcrypt.GenerateFromPassword([]byte(pkey),
-			bcrypt.DefaultCost)
+		if !authmw.UsesSecret(client) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_request",
+				"error_description": "client authenticates with " + client.AuthMethod,
+			})
+			return
+		}
 
+		pkey, pkeyHash, err := newClientKey()
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":             "internal_server_error",
@@ -400,32 +486,34 @@ func (cntrl *DefaultAPIController) CreateClientRoute() gin.HandlerFunc {
 			return
 		}
 
-		// Create client model.
-		client := authdb.ClientModel{
-			ID:           "",
-			Name:         req.Name,
-			Description:  req.Description,
-			ResponseType: req.ResponseType,
-			RedirectURI:  req.RedirectURI,
-			Scope:        req.Scope,
-			Owner:        user.ID,
-			Key:          string(pkeyHash),
-			CreatedAt:    time.Now().Unix(),
-			TTL:          7890000, // 3 months.
+		// Expire previous keys at the end of the grace period, unless
+		// they expire sooner.
+		now := time.Now().Unix()
+		expiresAt := now + grace
+		keys := []authdb.ClientKey{}
+		for _, key := range client.ActiveKeys(now) {
+			if key.ExpiresAt == 0 || key.ExpiresAt > expiresAt {
+				key.ExpiresAt = expiresAt
+			}
+			if key.ExpiresAt > now {
+				keys = append(keys, key)
+			}
 		}
 
-		id, err := cntrl.db.Clients().Create(client)
-		if err != nil {
+		client.Keys = append(keys, authdb.ClientKey{Hash: pkeyHash, CreatedAt: now})
+		if _, err := cntrl.db.Clients().Update(client); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "internal server error",
+				"error":             "internal_server_error",
+				"error_description": "Internal server error. Please try again later",
 			})
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"message": "success",
-			"id":      id,
-			"pkey":    pkey,
+			"message":             "success",
+			"id":                  client.ID,
+			"pkey":                pkey,
+			"previous_expires_at": expiresAt,
 		})
 	}
 }
@@ -471,6 +559,21 @@ func (cntrl *DefaultAPIController) DeleteUserRoute() gin.HandlerFunc {
 			return
 		}
 
+		// Sign the user out everywhere before deleting them.
+		clients, err := cntrl.signedInClients(userID)
+		if err == nil {
+			err = cntrl.db.Tokens().DeleteByUser(userID)
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "could not delete user at this time, please try again later",
+			})
+			return
+		}
+
+		cntrl.logoutClients(userID, clients)
 		err = cntrl.db.Users().DeleteByID(userID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -486,6 +589,41 @@ func (cntrl *DefaultAPIController) DeleteUserRoute() gin.HandlerFunc {
 	}
 }
 
+// LogoutUserRoute signs a user out everywhere by revoking every token
+// issued to them, such as when their device is lost. Clients are notified
+// through their back-channel logout endpoints.
+func (cntrl *DefaultAPIController) LogoutUserRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Param("id")
+		if _, err := cntrl.db.Users().FindByID(userID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":             "not_found",
+				"error_description": "user not found",
+			})
+			return
+		}
+
+		clients, err := cntrl.signedInClients(userID)
+		if err == nil {
+			err = cntrl.db.Tokens().DeleteByUser(userID)
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "could not sign out user at this time, please try again later",
+			})
+			return
+		}
+
+		cntrl.logoutClients(userID, clients)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "user signed out successfully",
+		})
+	}
+}
+
 // DeleteClientRoute returns the gin middleware for deleting a client.
 func (cntrl *DefaultAPIController) DeleteClientRoute() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -519,6 +657,15 @@ func (cntrl *DefaultAPIController) DeleteClientRoute() gin.HandlerFunc {
 			return
 		}
 
+		// Revoke the client's tokens along with it.
+		if err := cntrl.db.Tokens().DeleteByClient(clientID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "could not delete client at this time, please try again later",
+			})
+			return
+		}
+
 		err = cntrl.db.Clients().DeleteByID(clientID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -572,7 +719,7 @@ func (cntrl *DefaultAPIController) GetClientsRoute() gin.HandlerFunc {
 			Name         string   `json:"name"`
 			Description  string   `json:"description"`
 			ResponseType string   `json:"response_type"`
-			RedirectURI  string   `json:"redirect_uri"`
+			RedirectURIs []string `json:"redirect_uris"`
 			Scope        []string `json:"scope"`
 			CreatedAt    int64    `json:"created_at"`
 			TTL          int64    `json:"ttl"`
@@ -583,7 +730,7 @@ func (cntrl *DefaultAPIController) GetClientsRoute() gin.HandlerFunc {
 		for _, client := range docs {
 			cp = append(cp, clientPublic{
 				client.ID, client.Name, client.Description,
-				client.ResponseType, client.RedirectURI,
+				client.ResponseType, client.RedirectURIs,
 				client.Scope, client.CreatedAt, client.TTL,
 			})
 		}
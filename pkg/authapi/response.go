@@ -0,0 +1,94 @@
+package authapi
+
+import (
+	"github.com/gin-gonic/gin"
+	"html/template"
+	"net/http"
+	"net/url"
+)
+
+// Authorization response modes.
+// See: https://openid.net/specs/oauth-v2-multiple-response-types-1_0.html#ResponseModes
+const (
+	ResponseModeQuery    = "query"
+	ResponseModeFragment = "fragment"
+	ResponseModeFormPost = "form_post"
+)
+
+// responseModes are the response modes accepted by the authorization route.
+var responseModes = []string{
+	ResponseModeQuery, ResponseModeFragment, ResponseModeFormPost,
+}
+
+// responseMode returns the response mode requested for responseType,
+// defaulting to query for codes and fragment for tokens. Returns false if
+// mode is unknown or would leak a token into the query string.
+// See: https://openid.net/specs/oauth-v2-multiple-response-types-1_0.html#Security
+func responseMode(responseType, mode string) (string, bool) {
+	if mode == "" {
+		if responseType == "token" {
+			return ResponseModeFragment, true
+		}
+		return ResponseModeQuery, true
+	}
+
+	if !hasScope(responseModes, mode) ||
+		(mode == ResponseModeQuery && responseType == "token") {
+		return "", false
+	}
+
+	return mode, true
+}
+
+// formPostTemplate auto-submits authorization response parameters to the
+// client's redirect URI.
+// See: https://openid.net/specs/oauth-v2-form-post-response-mode-1_0.html
+var formPostTemplate = template.Must(template.New("form_post").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Submit This Form</title></head>
+<body onload="document.forms[0].submit()">
+<form method="post" action="{{.Action}}">
+{{- range $key, $values := .Params}}{{range $values}}
+<input type="hidden" name="{{$key}}" value="{{.}}"/>
+{{- end}}{{end}}
+<noscript><button type="submit">Continue</button></noscript>
+</form>
+</body>
+</html>
+`))
+
+// authorizationResponse sends params to the client's redirect URI using
+// the given response mode.
+func authorizationResponse(c *gin.Context, redirectURI, mode string,
+	params url.Values) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_request",
+			"error_description": "redirect_uri is invalid",
+		})
+		return
+	}
+
+	switch mode {
+	case ResponseModeFormPost:
+		c.Header("Cache-Control", "no-store")
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		formPostTemplate.Execute(c.Writer, gin.H{
+			"Action": u.String(),
+			"Params": params,
+		})
+	case ResponseModeFragment:
+		u.Fragment = ""
+		c.Redirect(http.StatusFound, u.String()+"#"+params.Encode())
+	default:
+		// Keep query parameters registered with the redirect URI.
+		query := u.Query()
+		for key, values := range params {
+			query[key] = values
+		}
+		u.RawQuery = query.Encode()
+		c.Redirect(http.StatusFound, u.String())
+	}
+}
@@ -0,0 +1,82 @@
+package authapi
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"net/http"
+)
+
+// RevocationRoute revokes an access or refresh token on behalf of the
+// authenticated client that it was issued to. Revoking a refresh token
+// also revokes every access token minted from it, as well as the rest of
+// its rotation family.
+// See: https://datatracker.ietf.org/doc/html/rfc7009
+func (cntrl *DefaultAPIController) RevocationRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+
+		// Get authenticated client.
+		clientAny, _ := c.Get("client")
+		client, _ := clientAny.(authdb.ClientModel)
+
+		id := c.PostForm("token")
+		if id == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_request",
+				"error_description": "missing token parameter",
+			})
+			return
+		}
+
+		// Invalid or unknown tokens do not cause an error response,
+		// since the client cannot handle it in any reasonable way.
+		token, kind, ok := cntrl.findToken(id, c.PostForm("token_type_hint"))
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{"message": "success"})
+			return
+		}
+
+		if token.ClientID != client.ID {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "unauthorized_client",
+				"error_description": "token was not issued to this client",
+			})
+			return
+		}
+
+		var err error
+		if kind == "refresh_token" {
+			err = cntrl.revokeFamily(token)
+		} else {
+			err = cntrl.db.Tokens().DeleteAccessByID(token.ID)
+		}
+
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "could not revoke token at this time, please try again later",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	}
+}
+
+// revokeFamily deletes a refresh token along with every refresh and access
+// token in its rotation family.
+func (cntrl *DefaultAPIController) revokeFamily(token authdb.TokenModel) error {
+	if err := cntrl.db.Tokens().DeleteRefreshByID(token.ID); err != nil {
+		return err
+	}
+
+	if err := cntrl.db.Tokens().DeleteAccessByRefresh(token.ID); err != nil {
+		return err
+	}
+
+	if token.FamilyID == "" {
+		return nil
+	}
+
+	return cntrl.db.Tokens().DeleteFamily(token.FamilyID)
+}
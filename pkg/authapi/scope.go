@@ -0,0 +1,38 @@
+package authapi
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+)
+
+// grantScope downscopes a token request to the scope registered by client.
+// The openid scope is always granted as it only enables OpenID Connect.
+// If no scope is requested, the client's registered scope is granted.
+// The introspection scope is a privilege of the client and never granted.
+// Returns an empty scope if none of the requested values can be granted.
+func grantScope(client authdb.ClientModel, requested []string) []string {
+	if len(requested) == 0 {
+		requested = client.Scope
+	}
+
+	granted := []string{}
+	for _, value := range requested {
+		if hasScope(granted, value) || value == introspectionScope {
+			continue
+		}
+
+		if value == "openid" || hasScope(client.Scope, value) {
+			granted = append(granted, value)
+		}
+	}
+
+	return granted
+}
+
+// grantedScope returns the scope granted to the access token that
+// authenticated the request, as written to the context by authmw.
+func grantedScope(c *gin.Context) []string {
+	scopeAny, _ := c.Get("scope")
+	scope, _ := scopeAny.([]string)
+	return scope
+}
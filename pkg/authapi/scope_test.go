@@ -0,0 +1,50 @@
+package authapi
+
+import (
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"reflect"
+	"testing"
+)
+
+var scopeClient = authdb.ClientModel{Scope: []string{"public", "email"}}
+
+func TestGrantScope(t *testing.T) {
+	got := grantScope(scopeClient, []string{"email"})
+	if !reflect.DeepEqual(got, []string{"email"}) {
+		t.Errorf("requested scope not granted, got %v", got)
+	}
+
+	got = grantScope(scopeClient, []string{"openid", "public"})
+	if !reflect.DeepEqual(got, []string{"openid", "public"}) {
+		t.Errorf("openid not granted, got %v", got)
+	}
+
+	got = grantScope(scopeClient, []string{"email", "email"})
+	if !reflect.DeepEqual(got, []string{"email"}) {
+		t.Errorf("duplicate scope not dropped, got %v", got)
+	}
+}
+
+func TestGrantScopeDefault(t *testing.T) {
+	got := grantScope(scopeClient, nil)
+	if !reflect.DeepEqual(got, []string{"public", "email"}) {
+		t.Fatalf("registered scope not granted by default, got %v", got)
+	}
+
+	// The client's registered scope must not be aliased.
+	got[0] = "modify"
+	if scopeClient.Scope[0] != "public" {
+		t.Fatalf("grantScope returned the client's scope slice")
+	}
+}
+
+func TestGrantScopeUnregistered(t *testing.T) {
+	got := grantScope(scopeClient, []string{"public", "modify"})
+	if !reflect.DeepEqual(got, []string{"public"}) {
+		t.Errorf("unregistered scope not dropped, got %v", got)
+	}
+
+	if got := grantScope(scopeClient, []string{"modify", "dashboard"}); len(got) != 0 {
+		t.Errorf("unregistered scope granted, got %v", got)
+	}
+}
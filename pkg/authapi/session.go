@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/authmw"
 	"github.com/ufosc/OpenWebServices/pkg/common"
 	"golang.org/x/crypto/bcrypt"
 	"net/http"
@@ -175,12 +176,13 @@ func (cntrl *DefaultAPIController) SignInRoute() gin.HandlerFunc {
 		}
 
 		// Generate access token.
-		tk, err := cntrl.db.Tokens().CreateAccess(authdb.TokenModel{
+		tk, err := cntrl.createAccess(authdb.TokenModel{
 			ID:        common.UUID(),
-			ClientID:  "0",
+			ClientID:  authmw.DashboardClient.ID,
 			UserID:    userExists.ID,
 			CreatedAt: time.Now().Unix(),
 			TTL:       1200,
+			Scope:     authmw.DashboardClient.Scope,
 		})
 
 		if err != nil {
@@ -198,6 +200,86 @@ func (cntrl *DefaultAPIController) SignInRoute() gin.HandlerFunc {
 	}
 }
 
+// SignOutRoute signs the authenticated user out of the dashboard by
+// revoking the token used to call it. Clients that the user is signed in to
+// have their tokens revoked and are notified through their back-channel
+// logout endpoints, and their front-channel logout URLs are returned for
+// the dashboard to load.
+func (cntrl *DefaultAPIController) SignOutRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userAny, _ := c.Get("user")
+		user, ok := userAny.(authdb.UserModel)
+		tokenAny, _ := c.Get("token")
+		token, tokenOK := tokenAny.(authdb.TokenModel)
+		if !ok || !tokenOK {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "not_found",
+				"error_description": "User not found",
+			})
+			return
+		}
+
+		clients, err := cntrl.signedInClients(user.ID)
+		if err == nil {
+			err = cntrl.db.Tokens().DeleteAccessByID(token.ID)
+		}
+
+		// Revoke the tokens of the clients that are notified, so that
+		// they cannot be used after the user signs out.
+		for i := 0; err == nil && i < len(clients); i++ {
+			err = cntrl.db.Tokens().DeleteByUserClient(user.ID, clients[i].ID)
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "internal server error. Please try again later.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":                  "success",
+			"frontchannel_logout_uris": cntrl.logoutClients(user.ID, clients),
+		})
+	}
+}
+
+// LogoutAllRoute signs the authenticated user out everywhere by revoking
+// every token issued to them, including the one used to call it. Clients
+// are notified like in SignOutRoute.
+func (cntrl *DefaultAPIController) LogoutAllRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userAny, _ := c.Get("user")
+		user, ok := userAny.(authdb.UserModel)
+		if !ok {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "not_found",
+				"error_description": "User not found",
+			})
+			return
+		}
+
+		clients, err := cntrl.signedInClients(user.ID)
+		if err == nil {
+			err = cntrl.db.Tokens().DeleteByUser(user.ID)
+		}
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":             "internal_server_error",
+				"error_description": "internal server error. Please try again later.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":                  "success",
+			"frontchannel_logout_uris": cntrl.logoutClients(user.ID, clients),
+		})
+	}
+}
+
 // VerifyEmailRoute consumes an email verification reference.
 func (cntrl *DefaultAPIController) VerifyEmailRoute() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -16,6 +16,8 @@ type Database interface {
 	Users() UserController
 	Tokens() TokenController
 	Clients() ClientController
+	Consents() ConsentController
+	Resources() ResourceController
 }
 
 // MongoState synchronizes database state and shares the MongoClient
@@ -35,10 +37,12 @@ type CollectionController struct {
 
 // MongoDatabase implements database using a MongoDB connnection.
 type MongoDatabase struct {
-	state   MongoState
-	clients ClientController
-	tokens  TokenController
-	users   UserController
+	state     MongoState
+	clients   ClientController
+	tokens    TokenController
+	users     UserController
+	consents  ConsentController
+	resources ResourceController
 }
 
 // NewDatabase implements the Database interface using an underlying MongoDB
@@ -76,10 +80,56 @@ func NewDatabase(uri, name string) (*MongoDatabase, error) {
 	}
 	db.users = users
 
+	consents, err := NewConsentController(&db.state)
+	if err != nil {
+		return nil, err
+	}
+	db.consents = consents
+
+	resources, err := NewResourceController(&db.state)
+	if err != nil {
+		return nil, err
+	}
+	db.resources = resources
+
+	migrate(db)
 	initIndices(db)
 	return db, nil
 }
 
+// migrate upgrades documents written by older versions of the server.
+func migrate(db *MongoDatabase) {
+	clicol := db.state.Client.Database(db.state.Name).Collection("clients")
+
+	// Clients used to register a single redirect_uri.
+	_, err := clicol.UpdateMany(context.TODO(),
+		bson.M{"redirect_uri": bson.M{"$exists": true}},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{"redirect_uris": bson.A{"$redirect_uri"}}}},
+			{{Key: "$unset", Value: "redirect_uri"}},
+		})
+
+	if err != nil {
+		fmt.Println("unable to migrate client redirect URIs:", err)
+		os.Exit(1)
+	}
+
+	// Clients used to have a single secret.
+	_, err = clicol.UpdateMany(context.TODO(),
+		bson.M{"key": bson.M{"$exists": true}},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{"keys": bson.A{
+				bson.M{"hash": "$key", "createdAt": "$createdAt"},
+			}}}},
+			{{Key: "$unset", Value: "key"}},
+		})
+
+	if err != nil {
+		fmt.Println("unable to migrate client keys:", err)
+		os.Exit(1)
+	}
+}
+
 // initIndices initializes database indices.
 func initIndices(db *MongoDatabase) {
 	index := func(ttl int32) mongo.IndexModel {
@@ -95,6 +145,13 @@ func initIndices(db *MongoDatabase) {
 	acccol := db.state.Client.Database(db.state.Name).Collection("access_tokens")
 	autcol := db.state.Client.Database(db.state.Name).Collection("auth_tokens")
 	pencol := db.state.Client.Database(db.state.Name).Collection("pending_users")
+	devcol := db.state.Client.Database(db.state.Name).Collection("device_codes")
+	concol := db.state.Client.Database(db.state.Name).Collection("consents")
+	inicol := db.state.Client.Database(db.state.Name).Collection("initial_tokens")
+	parcol := db.state.Client.Database(db.state.Name).Collection("pushed_requests")
+	asscol := db.state.Client.Database(db.state.Name).Collection("client_assertions")
+	prfcol := db.state.Client.Database(db.state.Name).Collection("dpop_proofs")
+	rescol := db.state.Client.Database(db.state.Name).Collection("resources")
 
 	// Apply indices.
 	_, err := clicol.Indexes().CreateOne(context.TODO(), index(7890000))
@@ -127,6 +184,36 @@ func initIndices(db *MongoDatabase) {
 		os.Exit(1)
 	}
 
+	_, err = devcol.Indexes().CreateOne(context.TODO(), index(600))
+	if err != nil {
+		fmt.Println("unable to apply TTL to device_codes collection:", err)
+		os.Exit(1)
+	}
+
+	_, err = inicol.Indexes().CreateOne(context.TODO(), index(86400))
+	if err != nil {
+		fmt.Println("unable to apply TTL to initial_tokens collection:", err)
+		os.Exit(1)
+	}
+
+	_, err = parcol.Indexes().CreateOne(context.TODO(), index(90))
+	if err != nil {
+		fmt.Println("unable to apply TTL to pushed_requests collection:", err)
+		os.Exit(1)
+	}
+
+	_, err = asscol.Indexes().CreateOne(context.TODO(), index(600))
+	if err != nil {
+		fmt.Println("unable to apply TTL to client_assertions collection:", err)
+		os.Exit(1)
+	}
+
+	_, err = prfcol.Indexes().CreateOne(context.TODO(), index(600))
+	if err != nil {
+		fmt.Println("unable to apply TTL to dpop_proofs collection:", err)
+		os.Exit(1)
+	}
+
 	// Create a custom identifier index for tokens and verification
 	// emails. Default indices are not cryptographically random.
 	_, err = refcol.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
@@ -147,6 +234,51 @@ func initIndices(db *MongoDatabase) {
 		os.Exit(1)
 	}
 
+	_, err = acccol.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.M{"refresh_id": 1},
+	})
+
+	if err != nil {
+		fmt.Println("cannot apply index to access_token collection", err)
+		os.Exit(1)
+	}
+
+	_, err = acccol.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.M{"family_id": 1},
+	})
+
+	if err != nil {
+		fmt.Println("cannot apply index to access_token collection", err)
+		os.Exit(1)
+	}
+
+	_, err = acccol.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.M{"code_id": 1},
+	})
+
+	if err != nil {
+		fmt.Println("cannot apply index to access_token collection", err)
+		os.Exit(1)
+	}
+
+	_, err = refcol.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.M{"family_id": 1},
+	})
+
+	if err != nil {
+		fmt.Println("cannot apply index to refresh_token collection", err)
+		os.Exit(1)
+	}
+
+	_, err = refcol.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.M{"code_id": 1},
+	})
+
+	if err != nil {
+		fmt.Println("cannot apply index to refresh_token collection", err)
+		os.Exit(1)
+	}
+
 	_, err = autcol.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
 		Keys: bson.M{"ID": 1},
 	})
@@ -156,6 +288,20 @@ func initIndices(db *MongoDatabase) {
 		os.Exit(1)
 	}
 
+	// Index token owners, so that all tokens of a user or client can be
+	// revoked at once.
+	for _, col := range []*mongo.Collection{refcol, acccol, autcol} {
+		_, err = col.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+			{Keys: bson.M{"user_id": 1}},
+			{Keys: bson.M{"client_id": 1}},
+		})
+
+		if err != nil {
+			fmt.Println("cannot apply index to", col.Name(), "collection", err)
+			os.Exit(1)
+		}
+	}
+
 	_, err = pencol.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
 		Keys: bson.M{"ID": 1},
 	})
@@ -164,6 +310,83 @@ func initIndices(db *MongoDatabase) {
 		fmt.Println("cannot apply index to pending_users collection", err)
 		os.Exit(1)
 	}
+
+	_, err = devcol.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.M{"ID": 1},
+	})
+
+	if err != nil {
+		fmt.Println("cannot apply index to device_codes collection", err)
+		os.Exit(1)
+	}
+
+	_, err = devcol.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"user_code": 1},
+		Options: options.Index().SetUnique(true),
+	})
+
+	if err != nil {
+		fmt.Println("cannot apply index to device_codes collection", err)
+		os.Exit(1)
+	}
+
+	_, err = inicol.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.M{"ID": 1},
+	})
+
+	if err != nil {
+		fmt.Println("cannot apply index to initial_tokens collection", err)
+		os.Exit(1)
+	}
+
+	_, err = parcol.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.M{"ID": 1},
+	})
+
+	if err != nil {
+		fmt.Println("cannot apply index to pushed_requests collection", err)
+		os.Exit(1)
+	}
+
+	_, err = asscol.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"ID": 1},
+		Options: options.Index().SetUnique(true),
+	})
+
+	if err != nil {
+		fmt.Println("cannot apply index to client_assertions collection", err)
+		os.Exit(1)
+	}
+
+	_, err = prfcol.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"ID": 1},
+		Options: options.Index().SetUnique(true),
+	})
+
+	if err != nil {
+		fmt.Println("cannot apply index to dpop_proofs collection", err)
+		os.Exit(1)
+	}
+
+	_, err = concol.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "client_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+
+	if err != nil {
+		fmt.Println("cannot apply index to consents collection", err)
+		os.Exit(1)
+	}
+
+	_, err = rescol.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"audience": 1},
+		Options: options.Index().SetUnique(true),
+	})
+
+	if err != nil {
+		fmt.Println("cannot apply index to resources collection", err)
+		os.Exit(1)
+	}
 }
 
 // Stop the database.
@@ -209,3 +432,20 @@ func (db *MongoDatabase) Clients() ClientController {
 	}
 	return db.clients
 }
+
+// Consents returns the database consent controller. Returns nil if closed.
+func (db *MongoDatabase) Consents() ConsentController {
+	if db.state.Stopped.Load() {
+		return nil
+	}
+	return db.consents
+}
+
+// Resources returns the database resource server controller. Returns nil
+// if closed.
+func (db *MongoDatabase) Resources() ResourceController {
+	if db.state.Stopped.Load() {
+		return nil
+	}
+	return db.resources
+}
@@ -9,23 +9,80 @@ import (
 
 // ClientModel is the client schema.
 type ClientModel struct {
-	ID           string   `bson:"_id,omitempty"`
-	Name         string   `bson:"name"`
-	Description  string   `bson:"description"`
-	ResponseType string   `bson:"response_type"`
-	RedirectURI  string   `bson:"redirect_uri"`
-	Scope        []string `bson:"scope"`
-	Owner        string   `bson:"owner"`
-	Key          string   `bson:"key"`
-	CreatedAt    int64    `bson:"createdAt"`
-	TTL          int64    `bson:"expireAfterSeconds"`
+	ID           string      `bson:"_id,omitempty"`
+	Name         string      `bson:"name"`
+	Description  string      `bson:"description"`
+	ResponseType string      `bson:"response_type"`
+	RedirectURIs []string    `bson:"redirect_uris"`
+	Scope        []string    `bson:"scope"`
+	Owner        string      `bson:"owner"`
+	Keys         []ClientKey `bson:"keys"`
+	CreatedAt    int64       `bson:"createdAt"`
+	TTL          int64       `bson:"expireAfterSeconds"`
+
+	// RegistrationKey is the hashed registration access token of a
+	// dynamically registered client, used to manage its registration.
+	// See: https://datatracker.ietf.org/doc/html/rfc7592
+	RegistrationKey string `bson:"registration_key,omitempty"`
+
+	// RequirePAR rejects authorization requests that were not pushed
+	// to the server beforehand.
+	RequirePAR bool `bson:"require_par"`
+
+	// AuthMethod is the token endpoint authentication method of the
+	// client. Clients authenticate with their secret (Key) unless it
+	// is "private_key_jwt", in which case they sign client assertions
+	// with a key from JWKS, a JSON-encoded key set, or the key set
+	// served at JWKSURI.
+	// See: https://datatracker.ietf.org/doc/html/rfc7523#section-2.2
+	AuthMethod string `bson:"auth_method"`
+	JWKS       string `bson:"jwks"`
+	JWKSURI    string `bson:"jwks_uri"`
+
+	// Clients using "tls_client_auth" authenticate with a certificate
+	// issued by a trusted CA to the registered subject DN or DNS name.
+	// Clients using "self_signed_tls_client_auth" authenticate with a
+	// certificate for a key in JWKS or served at JWKSURI.
+	// See: https://datatracker.ietf.org/doc/html/rfc8705#section-2
+	TLSClientAuthSubjectDN string `bson:"tls_client_auth_subject_dn"`
+	TLSClientAuthSANDNS    string `bson:"tls_client_auth_san_dns"`
+
+	// Logout endpoints of the client, notified when a user signs out.
+	// Logout tokens are POSTed to BackchannelLogoutURI and the dashboard
+	// loads FrontchannelLogoutURI in an iframe.
+	// See: https://openid.net/specs/openid-connect-backchannel-1_0.html
+	// See: https://openid.net/specs/openid-connect-frontchannel-1_0.html
+	BackchannelLogoutURI  string `bson:"backchannel_logout_uri"`
+	FrontchannelLogoutURI string `bson:"frontchannel_logout_uri"`
+}
+
+// ClientKey is a hashed client secret. Secrets remain valid until ExpiresAt,
+// or indefinitely if it is zero, so that a rotated secret keeps working
+// while the client is redeployed with its replacement.
+type ClientKey struct {
+	Hash      string `bson:"hash"`
+	CreatedAt int64  `bson:"createdAt"`
+	ExpiresAt int64  `bson:"expires_at,omitempty"`
+}
+
+// ActiveKeys returns the client secrets that have not expired at now.
+func (client ClientModel) ActiveKeys(now int64) []ClientKey {
+	keys := []ClientKey{}
+	for _, key := range client.Keys {
+		if key.ExpiresAt == 0 || key.ExpiresAt > now {
+			keys = append(keys, key)
+		}
+	}
+	return keys
 }
 
 // ClientController defines database operations for the OAuth2 client model.
 type ClientController interface {
 	FindByID(string) (ClientModel, error)
 	FindByName(string) (ClientModel, error)
+	FindByIDs([]string) ([]ClientModel, error)
 	Create(ClientModel) (string, error)
+	Update(ClientModel) (int64, error)
 	DeleteByID(string) error
 	Batch(n, skip int64) ([]ClientModel, error)
 	Count() (int64, error)
@@ -77,6 +134,39 @@ func (cc *MongoClientController) FindByID(id string) (ClientModel, error) {
 	return client, nil
 }
 
+// FindByIDs finds the client programs with the given IDs. IDs that do not
+// identify a client are ignored.
+func (cc *MongoClientController) FindByIDs(ids []string) ([]ClientModel, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.coll == nil {
+		return []ClientModel{}, ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	// Extract primitive object IDs.
+	objIDs := []primitive.ObjectID{}
+	for _, id := range ids {
+		if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+			objIDs = append(objIDs, objID)
+		}
+	}
+
+	cursor, err := cc.coll.Find(context.TODO(),
+		bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: objIDs}}}})
+
+	if err != nil {
+		return []ClientModel{}, err
+	}
+
+	result := []ClientModel{}
+	if err := cursor.All(context.TODO(), &result); err != nil {
+		return []ClientModel{}, err
+	}
+
+	return result, nil
+}
+
 // FindByName finds a client program by its advertised name.
 func (cc *MongoClientController) FindByName(name string) (ClientModel, error) {
 	if cc.state == nil || cc.state.Stopped.Load() || cc.coll == nil {
@@ -114,6 +204,32 @@ func (cc *MongoClientController) Create(client ClientModel) (string, error) {
 	return res.InsertedID.(primitive.ObjectID).Hex(), nil
 }
 
+// Update the given client and synchronize its state with the database.
+func (cc *MongoClientController) Update(client ClientModel) (int64, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.coll == nil {
+		return 0, ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	// Extract primitive object ID.
+	objID, err := primitive.ObjectIDFromHex(client.ID)
+	if err != nil {
+		return 0, err
+	}
+	client.ID = ""
+
+	res, err := cc.coll.UpdateOne(context.TODO(), bson.D{{Key: "_id", Value: objID}},
+		bson.D{{Key: "$set", Value: client}})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return res.ModifiedCount, nil
+}
+
 // DeleteByID deletes the client with the given id.
 func (cc *MongoClientController) DeleteByID(id string) error {
 	if cc.state == nil || cc.state.Stopped.Load() || cc.coll == nil {
@@ -0,0 +1,136 @@
+package authdb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// ConsentModel records the scope that a user has granted to a client.
+type ConsentModel struct {
+	_id       string   `bson:"_id,omitempty"`
+	UserID    string   `bson:"user_id"`
+	ClientID  string   `bson:"client_id"`
+	Scope     []string `bson:"scope"`
+	CreatedAt int64    `bson:"createdAt"`
+	UpdatedAt int64    `bson:"updated_at"`
+}
+
+// ConsentController defines database operations for the consent model.
+type ConsentController interface {
+	Find(userID, clientID string) (ConsentModel, error)
+	FindByUser(userID string) ([]ConsentModel, error)
+	Grant(userID, clientID string, scope []string) error
+	Delete(userID, clientID string) error
+}
+
+// MongoConsentController implements ConsentController using MongoDB.
+type MongoConsentController CollectionController
+
+// NewConsentController creates a MongoDB consent controller using the
+// provided database state.
+func NewConsentController(state *MongoState) (ConsentController, error) {
+	if state == nil {
+		return nil, ErrNilState
+	}
+
+	if state.Stopped.Load() {
+		return nil, ErrClosed
+	}
+
+	ctrl := new(MongoConsentController)
+	ctrl.coll = state.Client.Database(state.Name).Collection("consents")
+	ctrl.state = state
+
+	return ctrl, nil
+}
+
+// Find finds the consent that a user has given to a client.
+func (cc *MongoConsentController) Find(userID, clientID string) (
+	ConsentModel, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.coll == nil {
+		return ConsentModel{}, ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	var consent ConsentModel
+	err := cc.coll.FindOne(context.TODO(), bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "client_id", Value: clientID},
+	}).Decode(&consent)
+
+	if err != nil {
+		return ConsentModel{}, err
+	}
+
+	return consent, nil
+}
+
+// FindByUser finds the consents that a user has given to any client.
+func (cc *MongoConsentController) FindByUser(userID string) (
+	[]ConsentModel, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.coll == nil {
+		return []ConsentModel{}, ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	cursor, err := cc.coll.Find(context.TODO(),
+		bson.D{{Key: "user_id", Value: userID}})
+
+	if err != nil {
+		return []ConsentModel{}, err
+	}
+
+	result := []ConsentModel{}
+	if err := cursor.All(context.TODO(), &result); err != nil {
+		return []ConsentModel{}, err
+	}
+
+	return result, nil
+}
+
+// Grant adds scope to the consent that a user has given to a client,
+// creating it if necessary.
+func (cc *MongoConsentController) Grant(userID, clientID string,
+	scope []string) error {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.coll == nil {
+		return ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	now := time.Now().Unix()
+	_, err := cc.coll.UpdateOne(context.TODO(), bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "client_id", Value: clientID},
+	}, bson.D{
+		{Key: "$addToSet", Value: bson.M{"scope": bson.M{"$each": scope}}},
+		{Key: "$set", Value: bson.M{"updated_at": now}},
+		{Key: "$setOnInsert", Value: bson.M{"createdAt": now}},
+	}, options.Update().SetUpsert(true))
+
+	return err
+}
+
+// Delete revokes the consent that a user has given to a client.
+func (cc *MongoConsentController) Delete(userID, clientID string) error {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.coll == nil {
+		return ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	_, err := cc.coll.DeleteOne(context.TODO(), bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "client_id", Value: clientID},
+	})
+
+	return err
+}
@@ -0,0 +1,173 @@
+package authdb
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ResourceModel is a resource server that clients can request audience
+// restricted tokens for. Audience is its resource indicator, an absolute
+// URI. ClientID is the client that the resource server authenticates as,
+// if any, which may then exchange tokens presented to it.
+// See: https://datatracker.ietf.org/doc/html/rfc8707
+type ResourceModel struct {
+	ID        string `bson:"_id,omitempty"`
+	Name      string `bson:"name"`
+	Audience  string `bson:"audience"`
+	ClientID  string `bson:"client_id,omitempty"`
+	Owner     string `bson:"owner"`
+	CreatedAt int64  `bson:"createdAt"`
+}
+
+// ResourceController defines database operations for the resource server
+// model.
+type ResourceController interface {
+	FindByID(string) (ResourceModel, error)
+	FindByAudience(string) (ResourceModel, error)
+	Create(ResourceModel) (string, error)
+	DeleteByID(string) error
+	Batch(n, skip int64) ([]ResourceModel, error)
+	Count() (int64, error)
+}
+
+// MongoResourceController implements ResourceController using MongoDB.
+type MongoResourceController CollectionController
+
+// NewResourceController creates a MongoDB resource server controller using
+// the provided database state.
+func NewResourceController(state *MongoState) (ResourceController, error) {
+	if state == nil {
+		return nil, ErrNilState
+	}
+
+	if state.Stopped.Load() {
+		return nil, ErrClosed
+	}
+
+	ctrl := new(MongoResourceController)
+	ctrl.coll = state.Client.Database(state.Name).Collection("resources")
+	ctrl.state = state
+
+	return ctrl, nil
+}
+
+// FindByID finds a resource server by its ID.
+func (cc *MongoResourceController) FindByID(id string) (ResourceModel, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.coll == nil {
+		return ResourceModel{}, ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	// Extract primitive object ID.
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ResourceModel{}, err
+	}
+
+	var resource ResourceModel
+	err = cc.coll.FindOne(context.TODO(), bson.D{{Key: "_id", Value: objID}}).Decode(&resource)
+	if err != nil {
+		return ResourceModel{}, err
+	}
+
+	return resource, nil
+}
+
+// FindByAudience finds a resource server by its resource indicator.
+func (cc *MongoResourceController) FindByAudience(audience string) (
+	ResourceModel, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.coll == nil {
+		return ResourceModel{}, ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	var resource ResourceModel
+	err := cc.coll.FindOne(context.TODO(),
+		bson.D{{Key: "audience", Value: audience}}).Decode(&resource)
+
+	if err != nil {
+		return ResourceModel{}, err
+	}
+
+	return resource, nil
+}
+
+// Create a resource server and save it to the database.
+func (cc *MongoResourceController) Create(resource ResourceModel) (string, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.coll == nil {
+		return "", ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	res, err := cc.coll.InsertOne(context.TODO(), resource)
+	if err != nil {
+		return "", err
+	}
+
+	return res.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+// DeleteByID deletes the resource server with the given id.
+func (cc *MongoResourceController) DeleteByID(id string) error {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.coll == nil {
+		return ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	// Extract primitive object ID.
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = cc.coll.DeleteOne(context.TODO(), bson.D{{Key: "_id", Value: objID}})
+	return err
+}
+
+func (cc *MongoResourceController) Batch(n, skip int64) ([]ResourceModel, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.coll == nil {
+		return []ResourceModel{}, ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+	cursor, err := cc.coll.Find(context.TODO(), bson.D{},
+		options.Find().SetLimit(n).SetSkip(skip))
+
+	if err != nil {
+		return []ResourceModel{}, err
+	}
+
+	result := []ResourceModel{}
+	if err := cursor.All(context.TODO(), &result); err != nil {
+		return []ResourceModel{}, err
+	}
+
+	return result, nil
+}
+
+func (cc *MongoResourceController) Count() (int64, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.coll == nil {
+		return -1, ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	count, err := cc.coll.CountDocuments(context.TODO(), bson.D{})
+	if err != nil {
+		return -1, err
+	}
+
+	return count, nil
+}
@@ -15,6 +15,118 @@ type TokenModel struct {
 	UserID    string `bson:"user_id"`
 	CreatedAt int64  `bson:"createdAt"`
 	TTL       int64  `bson:"expireAfterSeconds"`
+
+	// PKCE code challenge, only set on authorization codes.
+	CodeChallenge       string `bson:"code_challenge,omitempty"`
+	CodeChallengeMethod string `bson:"code_challenge_method,omitempty"`
+
+	// RedirectURI is the redirect URI that an authorization code was
+	// requested with, which must be repeated when redeeming it.
+	RedirectURI string `bson:"redirect_uri,omitempty"`
+
+	// Scope is the scope granted to the token, a subset of the client's
+	// registered scope. Tokens issued before scopes were recorded have
+	// none and are authorized for the client's registered scope.
+	Scope []string `bson:"scope,omitempty"`
+
+	// OpenID Connect request parameters. AuthTime is the time at which
+	// the user signed in to the dashboard.
+	Nonce    string `bson:"nonce,omitempty"`
+	AuthTime int64  `bson:"auth_time,omitempty"`
+
+	// RefreshID is the refresh token that an access token was minted
+	// alongside or from, if any.
+	RefreshID string `bson:"refresh_id,omitempty"`
+
+	// Audience lists the clients that a delegated token may be presented
+	// to. Actors is the chain of clients acting on behalf of the user,
+	// the current actor first.
+	// See: https://datatracker.ietf.org/doc/html/rfc8693
+	Audience []string `bson:"audience,omitempty"`
+	Actors   []string `bson:"actors,omitempty"`
+
+	// JKT is the JWK thumbprint of the DPoP key that the token is bound
+	// to, if any. Bound tokens are only accepted with a DPoP proof
+	// signed by that key.
+	// See: https://datatracker.ietf.org/doc/html/rfc9449
+	JKT string `bson:"jkt,omitempty"`
+
+	// X5T is the thumbprint of the client certificate that the token is
+	// bound to, if any. Bound tokens are only accepted over a connection
+	// authenticated with that certificate.
+	// See: https://datatracker.ietf.org/doc/html/rfc8705#section-3
+	X5T string `bson:"x5t,omitempty"`
+
+	// Refresh token rotation lineage. All refresh tokens descending from
+	// the same grant, and the access tokens minted from them, share a
+	// FamilyID. ParentID is the refresh token that a token replaced and
+	// Rotated is set once a refresh token has itself been replaced.
+	FamilyID string `bson:"family_id,omitempty"`
+	ParentID string `bson:"parent_id,omitempty"`
+	Rotated  bool   `bson:"rotated,omitempty"`
+
+	// CodeID is the authorization code that a token descends from, if
+	// any. Tokens are revoked if their code is redeemed again.
+	// See: https://datatracker.ietf.org/doc/html/rfc6749#section-4.1.2
+	CodeID string `bson:"code_id,omitempty"`
+}
+
+// PushedRequestModel is an authorization request that a client pushed
+// ahead of redirecting the user, identified by its request URI.
+// See: https://datatracker.ietf.org/doc/html/rfc9126
+type PushedRequestModel struct {
+	_id       string            `bson:"_id,omitempty"`
+	ID        string            `bson:"ID"`
+	ClientID  string            `bson:"client_id"`
+	Params    map[string]string `bson:"params"`
+	CreatedAt int64             `bson:"createdAt"`
+	TTL       int64             `bson:"expireAfterSeconds"`
+}
+
+// AssertionModel records a client assertion that was used to authenticate,
+// so that it cannot be replayed before it expires. ID is the client ID and
+// the assertion's "jti" claim.
+type AssertionModel struct {
+	_id       string `bson:"_id,omitempty"`
+	ID        string `bson:"ID"`
+	ClientID  string `bson:"client_id"`
+	CreatedAt int64  `bson:"createdAt"`
+	TTL       int64  `bson:"expireAfterSeconds"`
+}
+
+// DPoPProofModel records a DPoP proof that was accepted, so that it cannot
+// be replayed while its "iat" claim is accepted. ID is the JWK thumbprint
+// of the proof's key and the proof's "jti" claim.
+type DPoPProofModel struct {
+	_id       string `bson:"_id,omitempty"`
+	ID        string `bson:"ID"`
+	JKT       string `bson:"jkt"`
+	CreatedAt int64  `bson:"createdAt"`
+	TTL       int64  `bson:"expireAfterSeconds"`
+}
+
+// Device authorization request statuses.
+const (
+	DevicePending  = "pending"
+	DeviceApproved = "approved"
+	DeviceDenied   = "denied"
+)
+
+// DeviceModel is a device authorization request, identified by its device
+// code. The user code is entered by the user on a secondary device.
+// See: https://datatracker.ietf.org/doc/html/rfc8628
+type DeviceModel struct {
+	_id        string   `bson:"_id,omitempty"`
+	ID         string   `bson:"ID"`
+	UserCode   string   `bson:"user_code"`
+	ClientID   string   `bson:"client_id"`
+	UserID     string   `bson:"user_id"`
+	Status     string   `bson:"status"`
+	Interval   int64    `bson:"interval"`
+	LastPolled int64    `bson:"last_polled"`
+	Scope      []string `bson:"scope,omitempty"`
+	CreatedAt  int64    `bson:"createdAt"`
+	TTL        int64    `bson:"expireAfterSeconds"`
 }
 
 // TokenController defines database operations for the OAuth2 token model.
@@ -24,17 +136,65 @@ type TokenController interface {
 	// Refresh tokens.
 	FindRefreshByID(string) (TokenModel, error)
 	CreateRefresh(TokenModel) (string, error)
+	RotateRefresh(string) (bool, error)
 	DeleteRefreshByID(string) error
+	DeleteFamily(string) error
+	DeleteByCode(string) error
+
+	// Bulk revocation of every refresh token, access token,
+	// authorization code and device code of a user or client.
+	DeleteByUser(string) error
+	DeleteByClient(string) error
+
+	// Per-user queries. FindByUser returns the unexpired refresh and
+	// access tokens of a user, and DeleteByUserClient revokes every
+	// token that a user granted to a client.
+	FindByUser(userID string, now int64) ([]TokenModel, error)
+	DeleteByUserClient(userID, clientID string) error
 
 	// Access tokens.
 	FindAccessByID(string) (TokenModel, error)
 	CreateAccess(TokenModel) (string, error)
 	DeleteAccessByID(string) error
+	DeleteAccessByRefresh(string) error
 
 	// Authorization tokens/codes.
 	FindAuthByID(string) (TokenModel, error)
 	CreateAuth(TokenModel) (string, error)
+	ConsumeAuth(string) (TokenModel, error)
 	DeleteAuthByID(string) error
+
+	// Initial access tokens, which authorize dynamic client
+	// registration. They are single use.
+	FindInitialByID(string) (TokenModel, error)
+	CreateInitial(TokenModel) (string, error)
+	ConsumeInitial(string) (TokenModel, error)
+	DeleteInitialByID(string) error
+
+	// Pushed authorization requests. They are single use, but can be
+	// found without consuming them to display them to the user.
+	CreatePushed(PushedRequestModel) (string, error)
+	FindPushedByID(string) (PushedRequestModel, error)
+	ConsumePushed(string) (PushedRequestModel, error)
+
+	// Client assertions. UseAssertion returns false if the assertion
+	// was already used.
+	UseAssertion(AssertionModel) (bool, error)
+
+	// DPoP proofs. UseDPoPProof returns false if the proof was already
+	// used.
+	UseDPoPProof(DPoPProofModel) (bool, error)
+
+	// Device authorization requests.
+	FindDeviceByID(string) (DeviceModel, error)
+	FindDeviceByUserCode(string) (DeviceModel, error)
+	CreateDevice(DeviceModel) (string, error)
+	UpdateDevice(DeviceModel) error
+	DeleteDeviceByID(string) error
+
+	// ConsumeDevice finds and deletes an approved device authorization
+	// request, so that each device code is exchanged once.
+	ConsumeDevice(string) (DeviceModel, error)
 }
 
 // MongoTokenController implements TokenController using MongoDB.
@@ -43,6 +203,11 @@ type MongoTokenController struct {
 	refreshColl *mongo.Collection
 	accessColl  *mongo.Collection
 	authColl    *mongo.Collection
+	deviceColl  *mongo.Collection
+	initColl    *mongo.Collection
+	pushedColl  *mongo.Collection
+	assertColl  *mongo.Collection
+	proofColl   *mongo.Collection
 }
 
 // NewTokenController creates a MongoDB user controller using the provided
@@ -60,6 +225,11 @@ func NewTokenController(state *MongoState) (TokenController, error) {
 	ctrl.refreshColl = state.Client.Database(state.Name).Collection("refresh_tokens")
 	ctrl.accessColl = state.Client.Database(state.Name).Collection("access_tokens")
 	ctrl.authColl = state.Client.Database(state.Name).Collection("auth_tokens")
+	ctrl.deviceColl = state.Client.Database(state.Name).Collection("device_codes")
+	ctrl.initColl = state.Client.Database(state.Name).Collection("initial_tokens")
+	ctrl.pushedColl = state.Client.Database(state.Name).Collection("pushed_requests")
+	ctrl.assertColl = state.Client.Database(state.Name).Collection("client_assertions")
+	ctrl.proofColl = state.Client.Database(state.Name).Collection("dpop_proofs")
 	ctrl.state = state
 
 	return ctrl, nil
@@ -115,6 +285,150 @@ func (cc *MongoTokenController) DeleteRefreshByID(id string) error {
 	return err
 }
 
+// RotateRefresh marks the refresh token with the given id as rotated. It
+// returns false if the token does not exist or was already rotated, which
+// guarantees that only one concurrent request can rotate a token.
+func (cc *MongoTokenController) RotateRefresh(id string) (bool, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.refreshColl == nil {
+		return false, ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+	res, err := cc.refreshColl.UpdateOne(context.TODO(),
+		bson.D{
+			{Key: "ID", Value: id},
+			{Key: "rotated", Value: bson.D{{Key: "$ne", Value: true}}},
+		},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "rotated", Value: true}}}})
+
+	if err != nil {
+		return false, err
+	}
+
+	return res.ModifiedCount == 1, nil
+}
+
+// DeleteFamily deletes every refresh and access token in the given
+// refresh token family.
+func (cc *MongoTokenController) DeleteFamily(family string) error {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.refreshColl == nil ||
+		cc.accessColl == nil {
+		return ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+	filter := bson.D{{Key: "family_id", Value: family}}
+	if _, err := cc.refreshColl.DeleteMany(context.TODO(), filter); err != nil {
+		return err
+	}
+
+	_, err := cc.accessColl.DeleteMany(context.TODO(), filter)
+	return err
+}
+
+// DeleteByCode deletes every refresh and access token that descends from
+// the given authorization code.
+func (cc *MongoTokenController) DeleteByCode(code string) error {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.refreshColl == nil ||
+		cc.accessColl == nil {
+		return ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+	filter := bson.D{{Key: "code_id", Value: code}}
+	if _, err := cc.refreshColl.DeleteMany(context.TODO(), filter); err != nil {
+		return err
+	}
+
+	_, err := cc.accessColl.DeleteMany(context.TODO(), filter)
+	return err
+}
+
+// DeleteByUser deletes every refresh token, access token, authorization
+// code and device code issued to the given user.
+func (cc *MongoTokenController) DeleteByUser(userID string) error {
+	return cc.deleteMany(bson.D{{Key: "user_id", Value: userID}})
+}
+
+// DeleteByClient deletes every refresh token, access token, authorization
+// code and device code issued to the given client.
+func (cc *MongoTokenController) DeleteByClient(clientID string) error {
+	return cc.deleteMany(bson.D{{Key: "client_id", Value: clientID}})
+}
+
+// DeleteByUserClient deletes every refresh token, access token,
+// authorization code and device code issued to the given client on behalf
+// of the given user.
+func (cc *MongoTokenController) DeleteByUserClient(userID, clientID string) error {
+	return cc.deleteMany(bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "client_id", Value: clientID},
+	})
+}
+
+// FindByUser finds the refresh and access tokens issued to the given user
+// that have not expired at now.
+func (cc *MongoTokenController) FindByUser(userID string, now int64) ([]TokenModel, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.refreshColl == nil ||
+		cc.accessColl == nil {
+		return []TokenModel{}, ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+	filter := bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "$expr", Value: bson.D{{Key: "$gte", Value: bson.A{
+			bson.D{{Key: "$add", Value: bson.A{"$createdAt", "$expireAfterSeconds"}}},
+			now,
+		}}}},
+	}
+
+	result := []TokenModel{}
+	for _, coll := range []*mongo.Collection{cc.refreshColl, cc.accessColl} {
+		cursor, err := coll.Find(context.TODO(), filter)
+		if err != nil {
+			return []TokenModel{}, err
+		}
+
+		tokens := []TokenModel{}
+		if err := cursor.All(context.TODO(), &tokens); err != nil {
+			return []TokenModel{}, err
+		}
+		result = append(result, tokens...)
+	}
+
+	return result, nil
+}
+
+func (cc *MongoTokenController) deleteMany(filter bson.D) error {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.refreshColl == nil ||
+		cc.accessColl == nil || cc.authColl == nil || cc.deviceColl == nil {
+		return ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+	if _, err := cc.refreshColl.DeleteMany(context.TODO(), filter); err != nil {
+		return err
+	}
+
+	if _, err := cc.accessColl.DeleteMany(context.TODO(), filter); err != nil {
+		return err
+	}
+
+	if _, err := cc.authColl.DeleteMany(context.TODO(), filter); err != nil {
+		return err
+	}
+
+	// Approved device codes can still be redeemed for tokens.
+	_, err := cc.deviceColl.DeleteMany(context.TODO(), filter)
+	return err
+}
+
 func (cc *MongoTokenController) FindAccessByID(id string) (TokenModel, error) {
 	if cc.state == nil || cc.state.Stopped.Load() || cc.accessColl == nil {
 		return TokenModel{}, ErrClosed
@@ -165,6 +479,21 @@ func (cc *MongoTokenController) DeleteAccessByID(id string) error {
 	return err
 }
 
+// DeleteAccessByRefresh deletes all access tokens that were minted from
+// the given refresh token.
+func (cc *MongoTokenController) DeleteAccessByRefresh(id string) error {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.accessColl == nil {
+		return ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+	_, err := cc.accessColl.DeleteMany(context.TODO(),
+		bson.D{{Key: "refresh_id", Value: id}})
+
+	return err
+}
+
 func (cc *MongoTokenController) FindAuthByID(id string) (TokenModel, error) {
 	if cc.state == nil || cc.state.Stopped.Load() || cc.authColl == nil {
 		return TokenModel{}, ErrClosed
@@ -202,6 +531,27 @@ func (cc *MongoTokenController) CreateAuth(tk TokenModel) (string, error) {
 	return tk.ID, nil
 }
 
+// ConsumeAuth finds and deletes an authorization code, so that each code
+// can only be redeemed once.
+func (cc *MongoTokenController) ConsumeAuth(id string) (TokenModel, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.authColl == nil {
+		return TokenModel{}, ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	var token TokenModel
+	err := cc.authColl.FindOneAndDelete(context.TODO(),
+		bson.D{{Key: "ID", Value: id}}).Decode(&token)
+
+	if err != nil {
+		return TokenModel{}, err
+	}
+
+	return token, nil
+}
+
 func (cc *MongoTokenController) DeleteAuthByID(id string) error {
 	if cc.state == nil || cc.state.Stopped.Load() || cc.authColl == nil {
 		return ErrClosed
@@ -214,3 +564,290 @@ func (cc *MongoTokenController) DeleteAuthByID(id string) error {
 
 	return err
 }
+
+func (cc *MongoTokenController) FindDeviceByID(id string) (DeviceModel, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.deviceColl == nil {
+		return DeviceModel{}, ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	// Find model.
+	var device DeviceModel
+	err := cc.deviceColl.FindOne(context.TODO(),
+		bson.D{{Key: "ID", Value: id}}).Decode(&device)
+
+	if err != nil {
+		return DeviceModel{}, err
+	}
+
+	return device, nil
+}
+
+func (cc *MongoTokenController) FindDeviceByUserCode(code string) (DeviceModel, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.deviceColl == nil {
+		return DeviceModel{}, ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	// Find model.
+	var device DeviceModel
+	err := cc.deviceColl.FindOne(context.TODO(),
+		bson.D{{Key: "user_code", Value: code}}).Decode(&device)
+
+	if err != nil {
+		return DeviceModel{}, err
+	}
+
+	return device, nil
+}
+
+func (cc *MongoTokenController) CreateDevice(device DeviceModel) (string, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.deviceColl == nil {
+		return "", ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	// Insert.
+	_, err := cc.deviceColl.InsertOne(context.TODO(), device)
+	if err != nil {
+		return "", err
+	}
+
+	return device.ID, nil
+}
+
+// UpdateDevice synchronizes the state of a device authorization request
+// with the database.
+func (cc *MongoTokenController) UpdateDevice(device DeviceModel) error {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.deviceColl == nil {
+		return ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+	_, err := cc.deviceColl.UpdateOne(context.TODO(),
+		bson.D{{Key: "ID", Value: device.ID}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "user_id", Value: device.UserID},
+			{Key: "status", Value: device.Status},
+			{Key: "interval", Value: device.Interval},
+			{Key: "last_polled", Value: device.LastPolled},
+		}}})
+
+	return err
+}
+
+func (cc *MongoTokenController) DeleteDeviceByID(id string) error {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.deviceColl == nil {
+		return ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+	_, err := cc.deviceColl.DeleteOne(context.TODO(),
+		bson.D{{Key: "ID", Value: id}})
+
+	return err
+}
+
+// ConsumeDevice finds and deletes an approved device authorization request
+// in a single operation. Concurrent exchanges of the same device code find
+// no document and fail.
+func (cc *MongoTokenController) ConsumeDevice(id string) (DeviceModel, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.deviceColl == nil {
+		return DeviceModel{}, ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	var device DeviceModel
+	err := cc.deviceColl.FindOneAndDelete(context.TODO(), bson.D{
+		{Key: "ID", Value: id},
+		{Key: "status", Value: DeviceApproved},
+	}).Decode(&device)
+
+	if err != nil {
+		return DeviceModel{}, err
+	}
+
+	return device, nil
+}
+
+func (cc *MongoTokenController) FindInitialByID(id string) (TokenModel, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.initColl == nil {
+		return TokenModel{}, ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	// Find model.
+	var token TokenModel
+	err := cc.initColl.FindOne(context.TODO(),
+		bson.D{{Key: "ID", Value: id}}).Decode(&token)
+
+	if err != nil {
+		return TokenModel{}, err
+	}
+
+	return token, nil
+}
+
+func (cc *MongoTokenController) CreateInitial(tk TokenModel) (string, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.initColl == nil {
+		return "", ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	// Insert.
+	_, err := cc.initColl.InsertOne(context.TODO(), tk)
+	if err != nil {
+		return "", err
+	}
+
+	return tk.ID, nil
+}
+
+// ConsumeInitial finds and deletes the initial access token with the given
+// id, so that it can only register one client.
+func (cc *MongoTokenController) ConsumeInitial(id string) (TokenModel, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.initColl == nil {
+		return TokenModel{}, ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	var token TokenModel
+	err := cc.initColl.FindOneAndDelete(context.TODO(),
+		bson.D{{Key: "ID", Value: id}}).Decode(&token)
+
+	if err != nil {
+		return TokenModel{}, err
+	}
+
+	return token, nil
+}
+
+func (cc *MongoTokenController) DeleteInitialByID(id string) error {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.initColl == nil {
+		return ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+	_, err := cc.initColl.DeleteOne(context.TODO(),
+		bson.D{{Key: "ID", Value: id}})
+
+	return err
+}
+
+func (cc *MongoTokenController) CreatePushed(req PushedRequestModel) (string, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.pushedColl == nil {
+		return "", ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	// Insert.
+	_, err := cc.pushedColl.InsertOne(context.TODO(), req)
+	if err != nil {
+		return "", err
+	}
+
+	return req.ID, nil
+}
+
+func (cc *MongoTokenController) FindPushedByID(id string) (PushedRequestModel, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.pushedColl == nil {
+		return PushedRequestModel{}, ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	var req PushedRequestModel
+	err := cc.pushedColl.FindOne(context.TODO(),
+		bson.D{{Key: "ID", Value: id}}).Decode(&req)
+
+	if err != nil {
+		return PushedRequestModel{}, err
+	}
+
+	return req, nil
+}
+
+// ConsumePushed finds and deletes the pushed authorization request with
+// the given id, so that its request URI can only be used once.
+func (cc *MongoTokenController) ConsumePushed(id string) (PushedRequestModel, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.pushedColl == nil {
+		return PushedRequestModel{}, ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	var req PushedRequestModel
+	err := cc.pushedColl.FindOneAndDelete(context.TODO(),
+		bson.D{{Key: "ID", Value: id}}).Decode(&req)
+
+	if err != nil {
+		return PushedRequestModel{}, err
+	}
+
+	return req, nil
+}
+
+// UseAssertion records a client assertion. Assertion IDs are unique, so
+// recording an assertion twice fails and returns false.
+func (cc *MongoTokenController) UseAssertion(assertion AssertionModel) (bool, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.assertColl == nil {
+		return false, ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	_, err := cc.assertColl.InsertOne(context.TODO(), assertion)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// UseDPoPProof records a DPoP proof. Proof IDs are unique, so recording a
+// proof twice fails and returns false.
+func (cc *MongoTokenController) UseDPoPProof(proof DPoPProofModel) (bool, error) {
+	if cc.state == nil || cc.state.Stopped.Load() || cc.proofColl == nil {
+		return false, ErrClosed
+	}
+
+	cc.state.Wg.Add(1)
+	defer cc.state.Wg.Done()
+
+	_, err := cc.proofColl.InsertOne(context.TODO(), proof)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
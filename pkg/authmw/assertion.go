@@ -17,7 +17,7 @@ func A(db authdb.Database) gin.HandlerFunc {
 		}
 
 		// Verify access token exists.
-		tkExists, err := db.Tokens().FindAccessByID(assertion)
+		tkExists, err := db.Tokens().FindAccessByID(tokenID(assertion))
 		if err != nil {
 			setError(c, ErrToken, "access token expired/not found")
 			return
@@ -31,7 +31,7 @@ func A(db authdb.Database) gin.HandlerFunc {
 
 		// Ensure key is not expired.
 		if (tkExists.CreatedAt + tkExists.TTL) < time.Now().Unix() {
-			db.Tokens().DeleteAccessByID(assertion)
+			db.Tokens().DeleteAccessByID(tkExists.ID)
 			setError(c, ErrToken, "Access token expired / not found")
 			return
 		}
@@ -39,12 +39,13 @@ func A(db authdb.Database) gin.HandlerFunc {
 		// Verify associated user exists.
 		userExists, err := db.Users().FindByID(tkExists.UserID)
 		if err != nil {
-			db.Tokens().DeleteAccessByID(assertion)
+			db.Tokens().DeleteAccessByID(tkExists.ID)
 			setError(c, ErrToken, "User not found")
 			return
 		}
 
 		c.Set("user", userExists)
+		c.Set("token", tkExists)
 		c.Next()
 	}
 }
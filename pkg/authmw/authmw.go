@@ -3,6 +3,7 @@ package authmw
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/common"
 	"net/http"
 	"strings"
 	"time"
@@ -13,6 +14,47 @@ import (
 type Config struct {
 	Scope  []string
 	Realms []string
+
+	// AllowClients accepts tokens issued to a client acting on its own
+	// behalf (client credentials grant), which have no associated user.
+	// Such tokens never satisfy realm requirements.
+	AllowClients bool
+
+	// Keys verifies the signature of JWT access tokens, whose claims are
+	// trusted without loading the user or client. The token's "jti" is
+	// still looked up to reject revoked tokens. JWTs are looked up like
+	// opaque tokens if empty. Issuer, if set, must match the token's
+	// "iss" claim.
+	Keys   common.JWKSet
+	Issuer string
+
+	// DPoPNonceKey derives the nonces that DPoP proofs must carry, and
+	// must match the authorization server's key to accept the nonces it
+	// hands out. Nonces are not required if empty.
+	DPoPNonceKey []byte
+
+	// ResourceURL is the externally reachable base URL of the routes,
+	// against which DPoP proofs are checked. It is derived from the
+	// request if empty.
+	ResourceURL string
+
+	// Audience identifies the routes as a resource server. Tokens
+	// restricted to other audiences are rejected, while tokens without
+	// an audience are accepted. Audiences are not checked if empty.
+	// See: https://datatracker.ietf.org/doc/html/rfc8707
+	Audience string
+}
+
+// DashboardClient is the built-in first-party client that tokens issued
+// at sign in belong to.
+var DashboardClient = authdb.ClientModel{
+	ID: "0",
+	Scope: []string{
+		"dashboard", "users.update",
+		"users.read", "users.delete",
+		"clients.read", "clients.delete",
+		"clients.create",
+	},
 }
 
 // WWW-Authenticate response header errors.
@@ -21,6 +63,13 @@ const (
 	ErrInvalid = "invalid_request"
 	ErrToken   = "invalid_token"
 	ErrScope   = "insufficient_scope"
+
+	// See: https://datatracker.ietf.org/doc/html/rfc6749#section-5.2
+	ErrClient = "invalid_client"
+
+	// See: https://datatracker.ietf.org/doc/html/rfc9449#section-12.2
+	ErrDPoPProof = "invalid_dpop_proof"
+	ErrDPoPNonce = "use_dpop_nonce"
 )
 
 func setError(c *gin.Context, code, desc string) {
@@ -30,7 +79,15 @@ func setError(c *gin.Context, code, desc string) {
 	scopesStr, _ := scopes.(string)
 	realmsStr, _ := realms.(string)
 
-	c.Header("WWW-Authenticate", "Bearer scope=\""+scopesStr+
+	scheme := "Bearer"
+	switch c.GetString("header-scheme") {
+	case "DPoP":
+		scheme = "DPoP algs=\"" + strings.Join(common.DPoPSigningAlgs, " ") + "\","
+	case "Basic":
+		scheme = "Basic"
+	}
+
+	c.Header("WWW-Authenticate", scheme+" scope=\""+scopesStr+
 		"\", realms=\""+realmsStr+"\", error=\""+code+
 		"\", error_description=\""+desc+"\"")
 
@@ -67,13 +124,20 @@ func X(db authdb.Database, config Config) gin.HandlerFunc {
 			return
 		}
 
-		if tkStr[0] != "Bearer" {
-			setError(c, ErrInvalid, "auth scheme must be Bearer")
+		if tkStr[0] != "Bearer" && tkStr[0] != "DPoP" {
+			setError(c, ErrInvalid, "auth scheme must be Bearer or DPoP")
+			return
+		}
+		c.Set("header-scheme", tkStr[0])
+
+		// Verify self-contained tokens locally.
+		if common.IsJWT(tkStr[1]) && len(config.Keys.Keys) > 0 {
+			verifyJWT(c, db, config, tkStr[0], tkStr[1])
 			return
 		}
 
 		// Verify Access token exists.
-		tkExists, err := db.Tokens().FindAccessByID(tkStr[1])
+		tkExists, err := db.Tokens().FindAccessByID(tokenID(tkStr[1]))
 		if err != nil {
 			setError(c, ErrToken, "access token expired/not found")
 			return
@@ -81,19 +145,42 @@ func X(db authdb.Database, config Config) gin.HandlerFunc {
 
 		// Ensure key is not expired.
 		if (tkExists.CreatedAt + tkExists.TTL) < time.Now().Unix() {
-			db.Tokens().DeleteAccessByID(tkStr[1])
+			db.Tokens().DeleteAccessByID(tkExists.ID)
 			setError(c, ErrToken, "Access token expired / not found")
 			return
 		}
 
-		// Verify associated user exists.
-		userExists, err := db.Users().FindByID(tkExists.UserID)
-		if err != nil {
-			db.Tokens().DeleteAccessByID(tkStr[1])
-			setError(c, ErrToken, "User not found")
+		// Verify token is presented the way it is bound.
+		if !checkBinding(c, db, config, tkStr[0], tkStr[1], tkExists.JKT) {
+			return
+		}
+
+		if !checkCertBinding(c, tkExists.X5T) {
+			return
+		}
+
+		if !audienceAllowed(config, tkExists.Audience) {
+			setError(c, ErrToken, "access token was issued for another resource")
+			return
+		}
+
+		// Client-only tokens must be explicitly allowed.
+		if tkExists.UserID == "" && !config.AllowClients {
+			setError(c, ErrToken, "access token must be issued to a user")
 			return
 		}
 
+		// Verify associated user exists.
+		var userExists authdb.UserModel
+		if tkExists.UserID != "" {
+			userExists, err = db.Users().FindByID(tkExists.UserID)
+			if err != nil {
+				db.Tokens().DeleteAccessByID(tkExists.ID)
+				setError(c, ErrToken, "User not found")
+				return
+			}
+		}
+
 		// Verify user has required realms.
 		haveRealms := map[string]bool{}
 		for _, realm := range userExists.Realms {
@@ -108,17 +195,8 @@ func X(db authdb.Database, config Config) gin.HandlerFunc {
 		}
 
 		// Verify associated client exists.
-		clientExists := authdb.ClientModel{
-			ID: "0",
-			Scope: []string{
-				"dashboard", "users.update",
-				"users.read", "users.delete",
-				"clients.read", "clients.delete",
-				"clients.create",
-			},
-		}
-
-		if tkExists.ClientID != "0" {
+		clientExists := DashboardClient
+		if tkExists.ClientID != DashboardClient.ID {
 			clientExists, err = db.Clients().FindByID(tkExists.ClientID)
 			if err != nil {
 				setError(c, ErrToken, "client not found")
@@ -126,22 +204,171 @@ func X(db authdb.Database, config Config) gin.HandlerFunc {
 			}
 		}
 
-		// Verify token is authorized for this route.
+		// Verify token is authorized for this route. Tokens issued
+		// before scopes were recorded carry the client's full scope.
+		scope := tkExists.Scope
+		if len(scope) == 0 {
+			scope = clientExists.Scope
+		}
+
 		haveScope := map[string]bool{}
-		for _, value := range clientExists.Scope {
+		for _, value := range scope {
 			haveScope[value] = true
 		}
 
 		for _, value := range config.Scope {
 			if !haveScope[value] {
-				setError(c, ErrScope, "insufficient client scope")
+				setError(c, ErrScope, "insufficient token scope")
 				return
 			}
 		}
 
-		// Write client, user, token to context.
-		c.Set("user", userExists)
+		// Write client, user, token and granted scope to context.
+		if tkExists.UserID != "" {
+			c.Set("user", userExists)
+		}
 		c.Set("client", clientExists)
+		c.Set("token", tkExists)
+		c.Set("scope", scope)
 		c.Next()
 	}
 }
+
+// audienceAllowed reports whether a token restricted to audience can be
+// presented to the routes.
+func audienceAllowed(config Config, audience []string) bool {
+	if config.Audience == "" || len(audience) == 0 {
+		return true
+	}
+
+	for _, aud := range audience {
+		if aud == config.Audience {
+			return true
+		}
+	}
+
+	return false
+}
+
+// tokenID returns the database ID of an access token string. JWT access
+// tokens are stored under their "jti" claim.
+func tokenID(token string) string {
+	if !common.IsJWT(token) {
+		return token
+	}
+
+	var claims common.AccessTokenClaims
+	if _, err := common.DecodeJWT(token, &claims); err != nil {
+		return ""
+	}
+
+	return claims.ID
+}
+
+// verifyJWT authenticates a request using a self-contained JWT access token
+// and the user realms and granted scope captured in its claims. The user and
+// client written to the context are therefore partial. Tokens are rejected
+// once they are revoked, which deletes them from the database.
+func verifyJWT(c *gin.Context, db authdb.Database, config Config, scheme,
+	token string) {
+	var claims common.AccessTokenClaims
+	header, err := common.VerifyJWTWithSet(token, config.Keys, &claims)
+	if err != nil || header.Typ != common.AccessTokenType {
+		setError(c, ErrToken, "invalid access token")
+		return
+	}
+
+	// Ensure key is not expired.
+	if claims.Expiry < time.Now().Unix() {
+		setError(c, ErrToken, "Access token expired / not found")
+		return
+	}
+
+	if config.Issuer != "" && claims.Issuer != config.Issuer {
+		setError(c, ErrToken, "access token issued by another server")
+		return
+	}
+
+	// Revoked tokens, and tokens of revoked grants, are deleted.
+	if _, err := db.Tokens().FindAccessByID(claims.ID); err != nil {
+		setError(c, ErrToken, "access token has been revoked")
+		return
+	}
+
+	// Verify token is presented the way it is bound.
+	jkt, x5t := "", ""
+	if claims.Confirm != nil {
+		jkt, x5t = claims.Confirm.JKT, claims.Confirm.X5T
+	}
+
+	if !checkBinding(c, db, config, scheme, token, jkt) || !checkCertBinding(c, x5t) {
+		return
+	}
+
+	if !audienceAllowed(config, claims.Audience) {
+		setError(c, ErrToken, "access token was issued for another resource")
+		return
+	}
+
+	// Client-only tokens must be explicitly allowed.
+	isClient := claims.Subject == claims.ClientID
+	if isClient && !config.AllowClients {
+		setError(c, ErrToken, "access token must be issued to a user")
+		return
+	}
+
+	// Verify user has required realms.
+	haveRealms := map[string]bool{}
+	for _, realm := range claims.Realms {
+		haveRealms[realm] = true
+	}
+
+	for _, realm := range config.Realms {
+		if !haveRealms[realm] {
+			setError(c, ErrScope, "missing realms")
+			return
+		}
+	}
+
+	// Verify token is authorized for this route.
+	scope := strings.Fields(claims.Scope)
+	haveScope := map[string]bool{}
+	for _, value := range scope {
+		haveScope[value] = true
+	}
+
+	for _, value := range config.Scope {
+		if !haveScope[value] {
+			setError(c, ErrScope, "insufficient token scope")
+			return
+		}
+	}
+
+	tk := authdb.TokenModel{
+		ID:        claims.ID,
+		ClientID:  claims.ClientID,
+		CreatedAt: claims.IssuedAt,
+		TTL:       claims.Expiry - claims.IssuedAt,
+		Scope:     scope,
+		Audience:  claims.Audience,
+		JKT:       jkt,
+		X5T:       x5t,
+	}
+
+	if claims.Actor != nil {
+		tk.Actors = claims.Actor.Chain()
+	}
+
+	// Write client, user, token to context.
+	if !isClient {
+		tk.UserID = claims.Subject
+		c.Set("user", authdb.UserModel{
+			ID:     claims.Subject,
+			Realms: claims.Realms,
+		})
+	}
+	c.Set("client", authdb.ClientModel{ID: claims.ClientID, Scope: scope})
+	c.Set("token", tk)
+	c.Set("scope", scope)
+	c.Next()
+}
@@ -0,0 +1,196 @@
+package authmw
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/common"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// errNotFound is returned by the test database for missing documents.
+var errNotFound = errors.New("document not found")
+
+// testDB is an in-memory authdb.Database. Controllers embed their
+// interface, so calling an operation that a test does not expect panics.
+type testDB struct {
+	users   *testUsers
+	tokens  *testTokens
+	clients *testClients
+}
+
+func newTestDB() *testDB {
+	gin.SetMode(gin.TestMode)
+	return &testDB{
+		users:   &testUsers{users: map[string]authdb.UserModel{}},
+		tokens:  &testTokens{used: map[string]bool{}, access: map[string]authdb.TokenModel{}},
+		clients: &testClients{clients: map[string]authdb.ClientModel{}},
+	}
+}
+
+func (db *testDB) Users() authdb.UserController         { return db.users }
+func (db *testDB) Tokens() authdb.TokenController       { return db.tokens }
+func (db *testDB) Clients() authdb.ClientController     { return db.clients }
+func (db *testDB) Consents() authdb.ConsentController   { return nil }
+func (db *testDB) Resources() authdb.ResourceController { return nil }
+
+type testUsers struct {
+	authdb.UserController
+	users map[string]authdb.UserModel
+}
+
+func (u *testUsers) FindByID(id string) (authdb.UserModel, error) {
+	if user, ok := u.users[id]; ok {
+		return user, nil
+	}
+	return authdb.UserModel{}, errNotFound
+}
+
+type testClients struct {
+	authdb.ClientController
+	clients map[string]authdb.ClientModel
+}
+
+func (cc *testClients) FindByID(id string) (authdb.ClientModel, error) {
+	if client, ok := cc.clients[id]; ok {
+		return client, nil
+	}
+	return authdb.ClientModel{}, errNotFound
+}
+
+func (cc *testClients) DeleteByID(id string) error {
+	delete(cc.clients, id)
+	return nil
+}
+
+type testTokens struct {
+	authdb.TokenController
+	used   map[string]bool
+	access map[string]authdb.TokenModel
+}
+
+func (tc *testTokens) UseAssertion(assertion authdb.AssertionModel) (bool, error) {
+	if tc.used[assertion.ID] {
+		return false, nil
+	}
+	tc.used[assertion.ID] = true
+	return true, nil
+}
+
+func (tc *testTokens) UseDPoPProof(proof authdb.DPoPProofModel) (bool, error) {
+	if tc.used[proof.ID] {
+		return false, nil
+	}
+	tc.used[proof.ID] = true
+	return true, nil
+}
+
+func (tc *testTokens) FindAccessByID(id string) (authdb.TokenModel, error) {
+	if token, ok := tc.access[id]; ok {
+		return token, nil
+	}
+	return authdb.TokenModel{}, errNotFound
+}
+
+// jwtRequest sends a request for /user with token to a router that
+// verifies JWT access tokens signed by key.
+func jwtRequest(key *common.SigningKey, token string) int {
+	db := newTestDB()
+	db.tokens.access["live"] = authdb.TokenModel{ID: "live"}
+
+	router := gin.New()
+	router.GET("/user", X(db, Config{
+		Scope:  []string{"public"},
+		Keys:   common.JWKSet{Keys: []common.JWK{key.JWK()}},
+		Issuer: "https://auth.example.com",
+	}), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+// genAccessToken signs access token claims for the token with id.
+func genAccessToken(t *testing.T, key *common.SigningKey, typ, id string,
+	expiry int64) string {
+	token, err := key.Sign(typ, common.AccessTokenClaims{
+		Issuer:   "https://auth.example.com",
+		Subject:  "user",
+		ClientID: "client",
+		Scope:    "public",
+		Expiry:   expiry,
+		IssuedAt: time.Now().Unix(),
+		ID:       id,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestXJWTAccessToken(t *testing.T) {
+	key, err := common.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := time.Now().Unix() + 60
+	token := genAccessToken(t, key, common.AccessTokenType, "live", exp)
+	if code := jwtRequest(key, token); code != http.StatusOK {
+		t.Fatalf("valid access token rejected with %d", code)
+	}
+}
+
+func TestXJWTAccessTokenRevoked(t *testing.T) {
+	key, err := common.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := time.Now().Unix() + 60
+	token := genAccessToken(t, key, common.AccessTokenType, "revoked", exp)
+	if code := jwtRequest(key, token); code != http.StatusUnauthorized {
+		t.Fatalf("revoked access token accepted with %d", code)
+	}
+}
+
+func TestXJWTAccessTokenExpired(t *testing.T) {
+	key, err := common.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := time.Now().Unix() - 1
+	token := genAccessToken(t, key, common.AccessTokenType, "live", exp)
+	if code := jwtRequest(key, token); code != http.StatusUnauthorized {
+		t.Fatalf("expired access token accepted with %d", code)
+	}
+}
+
+func TestXJWTAccessTokenBadSignature(t *testing.T) {
+	key, err := common.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := common.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := time.Now().Unix() + 60
+	token := genAccessToken(t, other, common.AccessTokenType, "live", exp)
+	if code := jwtRequest(key, token); code != http.StatusUnauthorized {
+		t.Errorf("access token signed by another key accepted with %d", code)
+	}
+
+	token = genAccessToken(t, key, "JWT", "live", exp)
+	if code := jwtRequest(key, token); code != http.StatusUnauthorized {
+		t.Errorf("token of another type accepted with %d", code)
+	}
+}
@@ -38,41 +38,131 @@ func B(db authdb.Database) gin.HandlerFunc {
 			return
 		}
 
-		// Verify client exists.
-		clientExists, err := db.Clients().FindByID(parts[0])
-		if err != nil {
-			setError(c, ErrToken, "client not found")
-			return
-		}
+		authenticate(c, db, ErrToken, parts[0], parts[1])
+	}
+}
 
-		// Client must be secure (code type).
-		if clientExists.ResponseType != "code" {
-			setError(c, ErrToken, "client is insecure")
-			return
+// C returns a middleware that authenticates clients at the token route,
+// using either the basic authorization scheme (client_secret_basic), the
+// client_id and client_secret form parameters (client_secret_post), a
+// signed client assertion (private_key_jwt) or, if no other credentials
+// are sent, the client certificate written to the context by ClientCert
+// (tls_client_auth and self_signed_tls_client_auth). Failures are reported
+// with the invalid_client error. issuer is the authorization server's
+// issuer identifier, which client assertions are addressed to.
+// See: https://datatracker.ietf.org/doc/html/rfc6749#section-2.3.1
+func C(db authdb.Database, issuer string) gin.HandlerFunc {
+	issuer = strings.TrimSuffix(issuer, "/")
+	return func(c *gin.Context) {
+		c.Set("header-scheme", "Basic")
+		header := c.GetHeader("Authorization") != ""
+		secret := c.PostForm("client_secret")
+		assertion := c.PostForm("client_assertion")
+
+		// Clients must use a single authentication method.
+		methods := 0
+		for _, used := range []bool{header, secret != "", assertion != ""} {
+			if used {
+				methods++
+			}
 		}
 
-		// Client must not be expired.
-		if (clientExists.CreatedAt + clientExists.TTL) <
-			time.Now().Unix() {
-			db.Clients().DeleteByID(clientExists.ID)
-			setError(c, ErrToken, "client has expired")
+		cert, _ := clientCert(c)
+		switch {
+		case methods == 0 && cert != nil:
+			authenticateCertificate(c, db, c.PostForm("client_id"))
+			return
+		case methods == 0:
+			setError(c, ErrClient, "expected Authorization header, client_secret, client_assertion or client certificate")
+			return
+		case methods > 1:
+			setError(c, ErrInvalid, "clients must use a single authentication method")
+			return
+		case assertion != "":
+			authenticateAssertion(c, db, issuer, assertion)
+			return
+		case secret != "":
+			authenticate(c, db, ErrClient, c.PostForm("client_id"), secret)
 			return
 		}
 
-		// Client owner must still exist.
-		if _, err := db.Users().FindByID(clientExists.Owner); err != nil {
-			db.Clients().DeleteByID(clientExists.ID)
-			setError(c, ErrToken, "client owner account no longer exists")
+		id, secret, ok := c.Request.BasicAuth()
+		if !ok {
+			setError(c, ErrClient, "invalid authorization token")
 			return
 		}
 
-		// Verify password.
-		if !common.VerifyPassword(clientExists.Key, parts[1]) {
-			setError(c, ErrToken, "incorrect key")
+		authenticate(c, db, ErrClient, id, secret)
+	}
+}
+
+// HasCredentials reports whether a request carries client credentials
+// that C would authenticate. Public clients send none.
+func HasCredentials(c *gin.Context) bool {
+	cert, _ := clientCert(c)
+	return c.GetHeader("Authorization") != "" ||
+		c.PostForm("client_secret") != "" ||
+		c.PostForm("client_assertion") != "" || cert != nil
+}
+
+// authenticate verifies the secret of a confidential client and writes the
+// client to the context. Failures are reported with code.
+func authenticate(c *gin.Context, db authdb.Database, code, id, secret string) {
+	clientExists, ok := confidentialClient(c, db, code, id)
+	if !ok {
+		return
+	}
+
+	// Clients that registered keys or certificates cannot use a secret.
+	if !UsesSecret(clientExists) {
+		setError(c, code, "client must authenticate with "+clientExists.AuthMethod)
+		return
+	}
+
+	// Verify password against each active key, as the previous key
+	// remains valid for a while after it is rotated.
+	for _, key := range clientExists.ActiveKeys(time.Now().Unix()) {
+		if common.VerifyPassword(key.Hash, secret) {
+			c.Set("client", clientExists)
+			c.Next()
 			return
 		}
+	}
+
+	setError(c, code, "incorrect key")
+}
 
-		c.Set("client", clientExists)
-		c.Next()
+// confidentialClient finds a client that is allowed to authenticate. It
+// writes an error response with code and returns false otherwise.
+func confidentialClient(c *gin.Context, db authdb.Database, code, id string) (
+	authdb.ClientModel, bool) {
+	// Verify client exists.
+	clientExists, err := db.Clients().FindByID(id)
+	if err != nil {
+		setError(c, code, "client not found")
+		return authdb.ClientModel{}, false
 	}
+
+	// Client must be secure (code type).
+	if clientExists.ResponseType != "code" {
+		setError(c, code, "client is insecure")
+		return authdb.ClientModel{}, false
+	}
+
+	// Client must not be expired.
+	if (clientExists.CreatedAt + clientExists.TTL) <
+		time.Now().Unix() {
+		db.Clients().DeleteByID(clientExists.ID)
+		setError(c, code, "client has expired")
+		return authdb.ClientModel{}, false
+	}
+
+	// Client owner must still exist.
+	if _, err := db.Users().FindByID(clientExists.Owner); err != nil {
+		db.Clients().DeleteByID(clientExists.ID)
+		setError(c, code, "client owner account no longer exists")
+		return authdb.ClientModel{}, false
+	}
+
+	return clientExists, true
 }
@@ -0,0 +1,228 @@
+package authmw
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/common"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// PrivateKeyJWT is the authentication method of clients that sign client
+// assertions with a registered key.
+const PrivateKeyJWT = "private_key_jwt"
+
+// maxAssertionAge is the longest time, in seconds, until a client assertion
+// may expire. Used assertions are remembered for this long.
+const maxAssertionAge = 600
+
+// Fetched key sets are cached for jwksCacheTTL, and fetched again sooner
+// only for unknown key IDs, at most once every jwksRefreshInterval.
+const (
+	jwksCacheTTL         = 5 * time.Minute
+	jwksRefreshInterval  = time.Minute
+	jwksMaxResponseBytes = 1 << 16
+)
+
+// jwksClient fetches client key sets from their JWKS URI.
+var jwksClient = PublicHTTPClient("jwks_uri")
+
+// cachedKeys is a key set fetched from a JWKS URI.
+type cachedKeys struct {
+	keys      common.JWKSet
+	fetchedAt time.Time
+}
+
+var (
+	jwksCacheMu sync.Mutex
+	jwksCache   = map[string]cachedKeys{}
+)
+
+// publicIP reports whether ip is a publicly routable address, rather than
+// a loopback, private, link-local, multicast or unspecified one.
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// PublicHTTPClient returns an HTTP client for requests to a URL that a
+// client registered as param. As the URL is chosen by the client, it only
+// connects to public addresses and does not follow redirects away from
+// https.
+func PublicHTTPClient(param string) *http.Client {
+	return &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: 5 * time.Second,
+				Control: func(network, address string, _ syscall.RawConn) error {
+					host, _, err := net.SplitHostPort(address)
+					if err != nil {
+						return err
+					}
+
+					if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+						return fmt.Errorf("%s resolves to a non-public address %s", param, host)
+					}
+					return nil
+				},
+			}).DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 || req.URL.Scheme != "https" {
+				return fmt.Errorf("%s redirected too often or away from https", param)
+			}
+			return nil
+		},
+	}
+}
+
+// ValidJWKSURI reports whether uri can be registered as a JWKS URI: an
+// https URL that does not name a loopback, private or link-local host.
+// Host names are checked again when they are resolved.
+func ValidJWKSURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" || u.User != nil {
+		return false
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+
+	ip := net.ParseIP(host)
+	return ip == nil || publicIP(ip)
+}
+
+// ClientKeys returns the key set that client signs assertions with, which
+// is either registered with the client or served at its JWKS URI. Served
+// key sets are cached, and fetched again early if kid is not in the set.
+func ClientKeys(client authdb.ClientModel, kid string) (common.JWKSet, error) {
+	var keys common.JWKSet
+	if client.JWKSURI == "" {
+		err := json.Unmarshal([]byte(client.JWKS), &keys)
+		return keys, err
+	}
+
+	if !ValidJWKSURI(client.JWKSURI) {
+		return keys, errors.New("jwks_uri must be a public https URL")
+	}
+
+	jwksCacheMu.Lock()
+	cached, ok := jwksCache[client.JWKSURI]
+	jwksCacheMu.Unlock()
+
+	if ok {
+		age := time.Since(cached.fetchedAt)
+		_, known := cached.keys.Find(kid)
+		if age < jwksRefreshInterval || (age < jwksCacheTTL && (kid == "" || known)) {
+			return cached.keys, nil
+		}
+	}
+
+	res, err := jwksClient.Get(client.JWKSURI)
+	if err != nil {
+		return keys, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return keys, fmt.Errorf("jwks_uri returned %s", res.Status)
+	}
+
+	err = json.NewDecoder(io.LimitReader(res.Body, jwksMaxResponseBytes)).Decode(&keys)
+	if err != nil {
+		return keys, err
+	}
+
+	jwksCacheMu.Lock()
+	jwksCache[client.JWKSURI] = cachedKeys{keys: keys, fetchedAt: time.Now()}
+	jwksCacheMu.Unlock()
+	return keys, nil
+}
+
+// authenticateAssertion authenticates a client using a signed client
+// assertion. Assertions are single use.
+// See: https://datatracker.ietf.org/doc/html/rfc7523#section-2.2
+func authenticateAssertion(c *gin.Context, db authdb.Database, issuer,
+	assertion string) {
+	if c.PostForm("client_assertion_type") != common.ClientAssertionType {
+		setError(c, ErrInvalid, "unsupported client_assertion_type")
+		return
+	}
+
+	// Find the client before verifying the assertion's signature, as
+	// the key depends on it.
+	var unverified common.ClientAssertionClaims
+	header, err := common.DecodeJWT(assertion, &unverified)
+	if err != nil {
+		setError(c, ErrClient, "malformed client assertion")
+		return
+	}
+
+	clientID := c.PostForm("client_id")
+	if clientID != "" && clientID != unverified.Subject {
+		setError(c, ErrClient, "client_id does not match client assertion")
+		return
+	}
+
+	client, ok := confidentialClient(c, db, ErrClient, unverified.Subject)
+	if !ok {
+		return
+	}
+
+	if client.AuthMethod != PrivateKeyJWT {
+		setError(c, ErrClient, "client has not registered keys")
+		return
+	}
+
+	keys, err := ClientKeys(client, header.Kid)
+	if err != nil {
+		setError(c, ErrClient, "client keys could not be loaded")
+		return
+	}
+
+	// Assertions are addressed to the endpoint they are sent to, the
+	// token endpoint or the server itself. Audiences are derived from
+	// the configured issuer, never from request headers.
+	audiences := []string{issuer, issuer + "/auth/token"}
+	if path := c.FullPath(); path != "" {
+		audiences = append(audiences, issuer+path)
+	}
+
+	now := time.Now().Unix()
+	claims, err := common.VerifyClientAssertion(assertion, keys, audiences,
+		maxAssertionAge, now)
+
+	if err != nil {
+		setError(c, ErrClient, err.Error())
+		return
+	}
+
+	used, err := db.Tokens().UseAssertion(authdb.AssertionModel{
+		ID:        client.ID + ":" + claims.ID,
+		ClientID:  client.ID,
+		CreatedAt: now,
+		TTL:       maxAssertionAge,
+	})
+
+	if err != nil || !used {
+		setError(c, ErrClient, "client assertion has already been used")
+		return
+	}
+
+	c.Set("client", client)
+	c.Next()
+}
@@ -0,0 +1,274 @@
+package authmw
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/common"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const assertionIssuer = "https://auth.example.com"
+
+func TestValidJWKSURI(t *testing.T) {
+	for _, uri := range []string{
+		"https://client.example.com/jwks.json",
+		"https://93.184.216.34/jwks.json",
+	} {
+		if !ValidJWKSURI(uri) {
+			t.Errorf("rejected public key set URI %s", uri)
+		}
+	}
+}
+
+func TestValidJWKSURIPrivateHost(t *testing.T) {
+	for _, uri := range []string{
+		"https://localhost/jwks.json",
+		"https://app.localhost/jwks.json",
+		"https://127.0.0.1/jwks.json",
+		"https://[::1]/jwks.json",
+		"https://10.0.0.8/jwks.json",
+		"https://192.168.1.1/jwks.json",
+		"https://169.254.169.254/latest/meta-data",
+		"https://[fe80::1]/jwks.json",
+		"https://0.0.0.0/jwks.json",
+	} {
+		if ValidJWKSURI(uri) {
+			t.Errorf("accepted key set URI on private host %s", uri)
+		}
+	}
+}
+
+func TestValidJWKSURIMalformed(t *testing.T) {
+	for _, uri := range []string{
+		"http://client.example.com/jwks.json",
+		"https://user@client.example.com/jwks.json",
+		"https:///jwks.json",
+		"not a url",
+	} {
+		if ValidJWKSURI(uri) {
+			t.Errorf("accepted key set URI %s", uri)
+		}
+	}
+}
+
+func TestClientKeysPrivateHost(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"keys":[]}`))
+		}))
+	defer server.Close()
+
+	client := authdb.ClientModel{AuthMethod: PrivateKeyJWT, JWKSURI: server.URL}
+	if _, err := ClientKeys(client, ""); err == nil {
+		t.Fatalf("fetched a key set from a loopback address")
+	}
+}
+
+// genAssertionRouter returns a router that authenticates clients with C
+// at the token and introspection endpoints, for a client using key.
+func genAssertionRouter(t *testing.T, key *common.SigningKey) *gin.Engine {
+	jwks, err := json.Marshal(common.JWKSet{Keys: []common.JWK{key.JWK()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Unix()
+	db := newTestDB()
+	db.users.users["owner"] = authdb.UserModel{ID: "owner"}
+	db.clients.clients["client"] = authdb.ClientModel{
+		ID:           "client",
+		ResponseType: "code",
+		Owner:        "owner",
+		AuthMethod:   PrivateKeyJWT,
+		JWKS:         string(jwks),
+		CreatedAt:    now,
+		TTL:          3600,
+	}
+	db.clients.clients["secret-client"] = authdb.ClientModel{
+		ID:           "secret-client",
+		ResponseType: "code",
+		Owner:        "owner",
+		CreatedAt:    now,
+		TTL:          3600,
+	}
+
+	router := gin.New()
+	router.POST("/auth/token", C(db, assertionIssuer+"/"),
+		func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/auth/introspect", C(db, assertionIssuer),
+		func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+// genAssertion signs an assertion by the client for aud with key.
+func genAssertion(t *testing.T, key *common.SigningKey, aud, id string,
+	expiry int64) string {
+	assertion, err := key.Sign("JWT", common.ClientAssertionClaims{
+		Issuer:   "client",
+		Subject:  "client",
+		Audience: common.Audience{aud},
+		Expiry:   expiry,
+		IssuedAt: time.Now().Unix(),
+		ID:       id,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return assertion
+}
+
+// assertionRequest posts assertion to path as the client with clientID,
+// if any, and returns the response status.
+func assertionRequest(router *gin.Engine, path, host, clientID,
+	assertionType, assertion string) int {
+	form := url.Values{
+		"client_assertion_type": {assertionType},
+		"client_assertion":      {assertion},
+	}
+	if clientID != "" {
+		form.Set("client_id", clientID)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if host != "" {
+		req.Host = host
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestClientAssertion(t *testing.T) {
+	key, err := common.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := genAssertionRouter(t, key)
+	exp := time.Now().Unix() + 60
+	assertion := genAssertion(t, key, assertionIssuer+"/auth/token", "1", exp)
+	if code := assertionRequest(router, "/auth/token", "", "client",
+		common.ClientAssertionType, assertion); code != http.StatusOK {
+		t.Errorf("assertion for the token endpoint rejected with %d", code)
+	}
+
+	assertion = genAssertion(t, key, assertionIssuer, "2", exp)
+	if code := assertionRequest(router, "/auth/introspect", "", "",
+		common.ClientAssertionType, assertion); code != http.StatusOK {
+		t.Errorf("assertion for the issuer rejected with %d", code)
+	}
+
+	assertion = genAssertion(t, key, assertionIssuer+"/auth/introspect", "3", exp)
+	if code := assertionRequest(router, "/auth/introspect", "", "client",
+		common.ClientAssertionType, assertion); code != http.StatusOK {
+		t.Errorf("assertion for the introspection endpoint rejected with %d", code)
+	}
+}
+
+func TestClientAssertionReplayed(t *testing.T) {
+	key, err := common.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := genAssertionRouter(t, key)
+	assertion := genAssertion(t, key, assertionIssuer, "1", time.Now().Unix()+60)
+	if code := assertionRequest(router, "/auth/token", "", "client",
+		common.ClientAssertionType, assertion); code != http.StatusOK {
+		t.Fatalf("assertion rejected with %d", code)
+	}
+
+	if code := assertionRequest(router, "/auth/token", "", "client",
+		common.ClientAssertionType, assertion); code != http.StatusUnauthorized {
+		t.Fatalf("replayed assertion accepted with %d", code)
+	}
+}
+
+func TestClientAssertionBadAudience(t *testing.T) {
+	key, err := common.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Audiences derive from the configured issuer, not the Host header.
+	router := genAssertionRouter(t, key)
+	exp := time.Now().Unix() + 60
+	assertion := genAssertion(t, key, "http://evil.example.com/auth/token", "1", exp)
+	if code := assertionRequest(router, "/auth/token", "evil.example.com", "client",
+		common.ClientAssertionType, assertion); code != http.StatusUnauthorized {
+		t.Errorf("assertion for a forged host accepted with %d", code)
+	}
+
+	assertion = genAssertion(t, key, assertionIssuer+"/auth/revoke", "2", exp)
+	if code := assertionRequest(router, "/auth/token", "", "client",
+		common.ClientAssertionType, assertion); code != http.StatusUnauthorized {
+		t.Errorf("assertion for another endpoint accepted with %d", code)
+	}
+}
+
+func TestClientAssertionBadAssertion(t *testing.T) {
+	key, err := common.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := common.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := genAssertionRouter(t, key)
+	now := time.Now().Unix()
+	expired := genAssertion(t, key, assertionIssuer, "1", now-1)
+	if code := assertionRequest(router, "/auth/token", "", "client",
+		common.ClientAssertionType, expired); code != http.StatusUnauthorized {
+		t.Errorf("expired assertion accepted with %d", code)
+	}
+
+	forged := genAssertion(t, other, assertionIssuer, "2", now+60)
+	if code := assertionRequest(router, "/auth/token", "", "client",
+		common.ClientAssertionType, forged); code != http.StatusUnauthorized {
+		t.Errorf("assertion signed by another key accepted with %d", code)
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	claims, _ := json.Marshal(common.ClientAssertionClaims{
+		Issuer: "client", Subject: "client", Audience: common.Audience{assertionIssuer},
+		Expiry: now + 60, IssuedAt: now, ID: "3",
+	})
+	unsigned := b64([]byte(`{"alg":"none","kid":"`+key.KeyID+`"}`)) + "." + b64(claims) + "."
+	if code := assertionRequest(router, "/auth/token", "", "client",
+		common.ClientAssertionType, unsigned); code != http.StatusUnauthorized {
+		t.Errorf("assertion with alg none accepted with %d", code)
+	}
+}
+
+func TestClientAssertionBadClient(t *testing.T) {
+	key, err := common.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := genAssertionRouter(t, key)
+	exp := time.Now().Unix() + 60
+	assertion := genAssertion(t, key, assertionIssuer, "1", exp)
+	if code := assertionRequest(router, "/auth/token", "", "secret-client",
+		common.ClientAssertionType, assertion); code != http.StatusUnauthorized {
+		t.Errorf("assertion for another client_id accepted with %d", code)
+	}
+
+	assertion = genAssertion(t, key, assertionIssuer, "2", exp)
+	if code := assertionRequest(router, "/auth/token", "", "",
+		"urn:example:unknown", assertion); code != http.StatusBadRequest {
+		t.Errorf("unknown assertion type not rejected with 400, got %d", code)
+	}
+}
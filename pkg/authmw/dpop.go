@@ -0,0 +1,94 @@
+package authmw
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/common"
+	"time"
+)
+
+// checkBinding verifies that a request presents token the way it is bound.
+// Tokens bound to a DPoP key (jkt) must use the DPoP auth scheme with a
+// proof signed by that key, and bearer tokens must use the Bearer scheme.
+// Proofs are single use.
+// See: https://datatracker.ietf.org/doc/html/rfc9449#section-7
+func checkBinding(c *gin.Context, db authdb.Database, config Config, scheme,
+	token, jkt string) bool {
+	if scheme == "Bearer" {
+		if jkt != "" {
+			setError(c, ErrToken, "DPoP-bound access token requires the DPoP auth scheme")
+			return false
+		}
+		return true
+	}
+
+	if jkt == "" {
+		setError(c, ErrToken, "access token is not DPoP-bound")
+		return false
+	}
+
+	proofs := c.Request.Header.Values("DPoP")
+	if len(proofs) != 1 {
+		setError(c, ErrDPoPProof, "expected a single DPoP proof")
+		return false
+	}
+
+	now := time.Now().Unix()
+	claims, proofJKT, err := common.VerifyDPoP(proofs[0], c.Request.Method,
+		requestURL(c, config), now)
+
+	if err != nil {
+		setError(c, ErrDPoPProof, err.Error())
+		return false
+	}
+
+	if claims.AccessTokenHash != common.AccessTokenHash(token) {
+		setError(c, ErrDPoPProof, "DPoP proof was not issued for this access token")
+		return false
+	}
+
+	key := config.DPoPNonceKey
+	if len(key) > 0 && !common.VerifyDPoPNonce(key, claims.Nonce, now) {
+		c.Header("DPoP-Nonce", common.NewDPoPNonce(key, now))
+		setError(c, ErrDPoPNonce, "resource server requires nonce in DPoP proof")
+		return false
+	}
+
+	if proofJKT != jkt {
+		setError(c, ErrDPoPProof, "DPoP proof key does not match access token")
+		return false
+	}
+
+	// Proofs are remembered for as long as their "iat" is accepted.
+	used, err := db.Tokens().UseDPoPProof(authdb.DPoPProofModel{
+		ID:        jkt + ":" + claims.ID,
+		JKT:       jkt,
+		CreatedAt: now,
+		TTL:       2 * common.DPoPMaxAge,
+	})
+
+	if err != nil || !used {
+		setError(c, ErrDPoPProof, "DPoP proof has already been used")
+		return false
+	}
+
+	return true
+}
+
+// requestURL returns the URL of the request without its query, as signed
+// in the "htu" claim of DPoP proofs.
+func requestURL(c *gin.Context, config Config) string {
+	if config.ResourceURL != "" {
+		return config.ResourceURL + c.Request.URL.Path
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+
+	return scheme + "://" + c.Request.Host + c.Request.URL.Path
+}
@@ -0,0 +1,146 @@
+package authmw
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/common"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// signProof signs a DPoP proof with key, embedding its public key.
+func signProof(t *testing.T, key *common.SigningKey, claims common.DPoPClaims) string {
+	jwk := key.JWK()
+	header, err := json.Marshal(common.JWTHeader{
+		Alg: "ES256", Typ: common.DPoPProofType, JWK: &jwk,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	input := b64(header) + "." + b64(payload)
+	sum := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key.Key.(*ecdsa.PrivateKey), sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return input + "." + b64(sig)
+}
+
+// dpopRequest sends a request for /user with a DPoP-bound token and proof.
+func dpopRequest(router *gin.Engine, scheme, proof string) int {
+	req := httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("Authorization", scheme+" token")
+	if proof != "" {
+		req.Header.Set("DPoP", proof)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+// genDPoPRouter returns a router serving /user to a token bound to key.
+func genDPoPRouter(t *testing.T, key *common.SigningKey) *gin.Engine {
+	jwk := key.JWK()
+	jkt, err := jwk.Thumbprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := newTestDB()
+	db.users.users["user"] = authdb.UserModel{ID: "user"}
+	db.clients.clients["client"] = authdb.ClientModel{ID: "client"}
+	db.tokens.access["token"] = authdb.TokenModel{
+		ID:        "token",
+		ClientID:  "client",
+		UserID:    "user",
+		CreatedAt: time.Now().Unix(),
+		TTL:       1200,
+		JKT:       jkt,
+	}
+
+	router := gin.New()
+	router.GET("/user", X(db, Config{ResourceURL: "https://api.example.com"}),
+		func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+// genProof returns proof claims for a request to url with the bound token.
+func genProof(id, method, url string) common.DPoPClaims {
+	return common.DPoPClaims{
+		ID:              id,
+		Method:          method,
+		URL:             url,
+		IssuedAt:        time.Now().Unix(),
+		AccessTokenHash: common.AccessTokenHash("token"),
+	}
+}
+
+func TestXDPoPProof(t *testing.T) {
+	key, err := common.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := genDPoPRouter(t, key)
+	proof := signProof(t, key, genProof("1", "GET", "https://api.example.com/user"))
+	if code := dpopRequest(router, "DPoP", proof); code != http.StatusOK {
+		t.Fatalf("valid proof rejected with %d", code)
+	}
+
+	if code := dpopRequest(router, "DPoP", proof); code != http.StatusUnauthorized {
+		t.Fatalf("replayed proof accepted with %d", code)
+	}
+}
+
+func TestXDPoPProofWrongRequest(t *testing.T) {
+	key, err := common.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := genDPoPRouter(t, key)
+	wrongMethod := signProof(t, key, genProof("1", "POST", "https://api.example.com/user"))
+	if code := dpopRequest(router, "DPoP", wrongMethod); code != http.StatusUnauthorized {
+		t.Errorf("proof for another method accepted with %d", code)
+	}
+
+	wrongURL := signProof(t, key, genProof("2", "GET", "https://api.example.com/users"))
+	if code := dpopRequest(router, "DPoP", wrongURL); code != http.StatusUnauthorized {
+		t.Errorf("proof for another URL accepted with %d", code)
+	}
+}
+
+func TestXDPoPProofMissing(t *testing.T) {
+	key, err := common.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := genDPoPRouter(t, key)
+	if code := dpopRequest(router, "DPoP", ""); code != http.StatusUnauthorized {
+		t.Errorf("DPoP token accepted without proof with %d", code)
+	}
+
+	if code := dpopRequest(router, "Bearer", ""); code != http.StatusUnauthorized {
+		t.Errorf("DPoP token accepted as a bearer token with %d", code)
+	}
+}
@@ -0,0 +1,196 @@
+package authmw
+
+import (
+	"crypto"
+	"crypto/x509"
+	"github.com/gin-gonic/gin"
+	"github.com/ufosc/OpenWebServices/pkg/authdb"
+	"github.com/ufosc/OpenWebServices/pkg/common"
+)
+
+// Mutual TLS client authentication methods.
+// See: https://datatracker.ietf.org/doc/html/rfc8705#section-2
+const (
+	TLSClientAuth           = "tls_client_auth"
+	SelfSignedTLSClientAuth = "self_signed_tls_client_auth"
+)
+
+// CertConfig configures where ClientCert finds client certificates.
+type CertConfig struct {
+	// Header is the request header that a TLS terminating proxy
+	// forwards client certificates in, as URL-encoded PEM. The proxy
+	// must overwrite the header, as it is trusted. Only certificates of
+	// direct TLS connections are used if empty.
+	Header string
+
+	// VerifyHeader is the request header that the proxy reports the
+	// result of verifying the forwarded certificate against its CAs
+	// in, "SUCCESS" if it was issued by one.
+	VerifyHeader string
+
+	// ClientCAs verifies certificates of direct TLS connections that
+	// the TLS handshake requested but did not verify, which lets the
+	// server accept self-signed certificates as well.
+	ClientCAs *x509.CertPool
+}
+
+// ClientCert returns a middleware that writes the client certificate of a
+// request to the context, for C to authenticate clients with and for X to
+// check certificate-bound tokens against. It never rejects requests.
+func ClientCert(config CertConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if state := c.Request.TLS; state != nil && len(state.PeerCertificates) > 0 {
+			c.Set("client-cert", state.PeerCertificates[0])
+			c.Set("client-cert-verified", len(state.VerifiedChains) > 0 ||
+				verifyCertificate(config.ClientCAs, state.PeerCertificates))
+		} else if value := c.GetHeader(config.Header); config.Header != "" && value != "" {
+			cert, err := common.ParseForwardedCertificate(value)
+			if err == nil {
+				c.Set("client-cert", cert)
+				c.Set("client-cert-verified", config.VerifyHeader != "" &&
+					c.GetHeader(config.VerifyHeader) == "SUCCESS")
+			}
+		}
+		c.Next()
+	}
+}
+
+// verifyCertificate reports whether the first of certs, followed by its
+// intermediates, was issued for client authentication by one of roots.
+func verifyCertificate(roots *x509.CertPool, certs []*x509.Certificate) bool {
+	if roots == nil || len(certs) == 0 {
+		return false
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return err == nil
+}
+
+// clientCert returns the client certificate written to the context by
+// ClientCert, if any, and whether it was issued by a trusted CA.
+func clientCert(c *gin.Context) (*x509.Certificate, bool) {
+	certAny, _ := c.Get("client-cert")
+	cert, _ := certAny.(*x509.Certificate)
+	return cert, c.GetBool("client-cert-verified")
+}
+
+// CertificateThumbprint returns the thumbprint of the certificate that C
+// authenticated the client with, which issued tokens are bound to. Empty
+// if the client did not authenticate with a certificate.
+func CertificateThumbprint(c *gin.Context) string {
+	return c.GetString("client-cert-x5t")
+}
+
+// PublicClient is the authentication method of public clients, which are
+// registered without credentials as they cannot keep them secret.
+const PublicClient = "none"
+
+// IsPublic reports whether client was registered as a public client. Public
+// clients never authenticate; they prove possession of PKCE code verifiers
+// and device codes instead.
+func IsPublic(client authdb.ClientModel) bool {
+	return client.AuthMethod == PublicClient
+}
+
+// UsesSecret reports whether client authenticates with its client secret,
+// rather than with keys or certificates.
+func UsesSecret(client authdb.ClientModel) bool {
+	switch client.AuthMethod {
+	case "", "client_secret_basic", "client_secret_post":
+		return true
+	}
+	return false
+}
+
+// authenticateCertificate authenticates a client using the certificate of
+// the request.
+// See: https://datatracker.ietf.org/doc/html/rfc8705#section-2
+func authenticateCertificate(c *gin.Context, db authdb.Database, id string) {
+	cert, verified := clientCert(c)
+	client, ok := confidentialClient(c, db, ErrClient, id)
+	if !ok {
+		return
+	}
+
+	switch client.AuthMethod {
+	case TLSClientAuth:
+		// PKI certificates must chain to a trusted CA and be issued
+		// to the registered subject.
+		if !verified {
+			setError(c, ErrClient, "client certificate was not issued by a trusted CA")
+			return
+		}
+
+		issued := client.TLSClientAuthSubjectDN != "" &&
+			cert.Subject.String() == client.TLSClientAuthSubjectDN
+		for _, name := range cert.DNSNames {
+			if client.TLSClientAuthSANDNS != "" && name == client.TLSClientAuthSANDNS {
+				issued = true
+			}
+		}
+
+		if !issued {
+			setError(c, ErrClient, "client certificate was not issued to this client")
+			return
+		}
+	case SelfSignedTLSClientAuth:
+		// Self-signed certificates must be for a registered key.
+		keys, err := ClientKeys(client, "")
+		if err != nil {
+			setError(c, ErrClient, "client keys could not be retrieved")
+			return
+		}
+
+		registered := false
+		for _, jwk := range keys.Keys {
+			key, err := jwk.PublicKey()
+			if err != nil {
+				continue
+			}
+
+			if pub, ok := key.(interface{ Equal(crypto.PublicKey) bool }); ok &&
+				pub.Equal(cert.PublicKey) {
+				registered = true
+				break
+			}
+		}
+
+		if !registered {
+			setError(c, ErrClient, "client certificate key is not registered")
+			return
+		}
+	default:
+		setError(c, ErrClient, "client does not authenticate with a certificate")
+		return
+	}
+
+	c.Set("client", client)
+	c.Set("client-cert-x5t", common.CertificateThumbprint(cert))
+	c.Next()
+}
+
+// checkCertBinding verifies that a token bound to a client certificate
+// (x5t) is presented over a connection authenticated with it.
+// See: https://datatracker.ietf.org/doc/html/rfc8705#section-3
+func checkCertBinding(c *gin.Context, x5t string) bool {
+	if x5t == "" {
+		return true
+	}
+
+	cert, _ := clientCert(c)
+	if cert == nil || common.CertificateThumbprint(cert) != x5t {
+		setError(c, ErrToken, "access token is bound to another client certificate")
+		return false
+	}
+
+	return true
+}
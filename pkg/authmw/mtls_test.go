@@ -0,0 +1,94 @@
+package authmw
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/gin-gonic/gin"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// genCertificate issues a client certificate signed by parent, or a
+// self-signed one if parent is nil.
+func genCertificate(t *testing.T, name string, isCA bool,
+	parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate,
+	*ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// verifyClientCert runs ClientCert on a direct TLS connection presenting
+// cert and reports whether it was verified.
+func verifyClientCert(roots *x509.CertPool, cert *x509.Certificate) bool {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/auth/token", nil)
+	c.Request.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	ClientCert(CertConfig{ClientCAs: roots})(c)
+	_, verified := clientCert(c)
+	return verified
+}
+
+func TestClientCertIssuedByCA(t *testing.T) {
+	ca, caKey := genCertificate(t, "ca", true, nil, nil)
+	cert, _ := genCertificate(t, "client", false, ca, caKey)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	if !verifyClientCert(roots, cert) {
+		t.Fatalf("certificate issued by a trusted CA was not verified")
+	}
+
+	if verifyClientCert(nil, cert) {
+		t.Fatalf("certificate verified without CAs configured")
+	}
+}
+
+func TestClientCertSelfSigned(t *testing.T) {
+	ca, _ := genCertificate(t, "ca", true, nil, nil)
+	cert, _ := genCertificate(t, "client", false, nil, nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	if verifyClientCert(roots, cert) {
+		t.Fatalf("self-signed certificate was verified")
+	}
+}
@@ -0,0 +1,81 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ClientAssertionType is the "client_assertion_type" of JWT client
+// assertions.
+// See: https://datatracker.ietf.org/doc/html/rfc7523#section-2.2
+const ClientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// ClientAssertionSigningAlgs are the algorithms that client assertions can
+// be signed with.
+var ClientAssertionSigningAlgs = []string{"ES256", "EdDSA", "RS256"}
+
+// Audience is the "aud" claim of a JWT, which may be a single string or an
+// array of strings.
+type Audience []string
+
+// UnmarshalJSON decodes a string or an array of strings.
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+
+	*a = multiple
+	return nil
+}
+
+// ClientAssertionClaims are the claims of a JWT that a client signs to
+// authenticate itself. Issuer and Subject are both the client ID.
+type ClientAssertionClaims struct {
+	Issuer   string   `json:"iss"`
+	Subject  string   `json:"sub"`
+	Audience Audience `json:"aud"`
+	Expiry   int64    `json:"exp"`
+	IssuedAt int64    `json:"iat,omitempty"`
+	ID       string   `json:"jti"`
+}
+
+// VerifyClientAssertion verifies a client assertion using the client's key
+// set. The assertion must be addressed to one of audiences and expire
+// within maxAge seconds. Replay protection on "jti" is left to the caller.
+// See: https://datatracker.ietf.org/doc/html/rfc7523#section-3
+func VerifyClientAssertion(assertion string, keys JWKSet, audiences []string,
+	maxAge, now int64) (ClientAssertionClaims, error) {
+	var claims ClientAssertionClaims
+	if _, err := VerifyJWTWithSet(assertion, keys, &claims); err != nil {
+		return claims, err
+	}
+
+	if claims.Issuer == "" || claims.Issuer != claims.Subject {
+		return claims, fmt.Errorf("client assertion iss and sub must be the client ID")
+	}
+
+	if claims.ID == "" {
+		return claims, fmt.Errorf("client assertion is missing jti")
+	}
+
+	if claims.Expiry < now || claims.Expiry > now+maxAge {
+		return claims, fmt.Errorf("client assertion is expired or expires too late")
+	}
+
+	for _, aud := range claims.Audience {
+		for _, valid := range audiences {
+			if aud == valid {
+				return claims, nil
+			}
+		}
+	}
+
+	return claims, fmt.Errorf("client assertion was not issued for this server")
+}
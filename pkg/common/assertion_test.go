@@ -0,0 +1,138 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+const (
+	assertionIssuer   = "https://auth.example.com"
+	assertionEndpoint = assertionIssuer + "/auth/token"
+	assertionMaxAge   = 600
+)
+
+var assertionAudiences = []string{assertionIssuer, assertionEndpoint}
+
+// genAssertion returns valid client assertion claims for the token endpoint.
+func genAssertion() ClientAssertionClaims {
+	now := time.Now().Unix()
+	return ClientAssertionClaims{
+		Issuer:   "client",
+		Subject:  "client",
+		Audience: Audience{assertionEndpoint},
+		Expiry:   now + 60,
+		IssuedAt: now,
+		ID:       "assertion-1",
+	}
+}
+
+// verifyAssertion signs claims with key and verifies them against keys.
+func verifyAssertion(key *SigningKey, keys JWKSet, claims ClientAssertionClaims) error {
+	kid := keys.Keys[0].Kid
+	assertion := signJWT(key, JWTHeader{Alg: "ES256", Kid: kid}, claims)
+	_, err := VerifyClientAssertion(assertion, keys, assertionAudiences,
+		assertionMaxAge, time.Now().Unix())
+	return err
+}
+
+func TestVerifyClientAssertion(t *testing.T) {
+	key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := JWKSet{Keys: []JWK{key.JWK()}}
+	if err := verifyAssertion(key, keys, genAssertion()); err != nil {
+		t.Fatalf("valid assertion rejected: %s", err)
+	}
+
+	claims := genAssertion()
+	claims.Audience = Audience{"https://other.example.com", assertionIssuer}
+	if err := verifyAssertion(key, keys, claims); err != nil {
+		t.Fatalf("assertion for the issuer rejected: %s", err)
+	}
+}
+
+func TestVerifyClientAssertionBadClaims(t *testing.T) {
+	key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := JWKSet{Keys: []JWK{key.JWK()}}
+	claims := genAssertion()
+	claims.Audience = Audience{"https://evil.example.com/auth/token"}
+	if verifyAssertion(key, keys, claims) == nil {
+		t.Errorf("accepted assertion for another audience")
+	}
+
+	claims = genAssertion()
+	claims.Expiry = claims.IssuedAt - 1
+	if verifyAssertion(key, keys, claims) == nil {
+		t.Errorf("accepted expired assertion")
+	}
+
+	claims = genAssertion()
+	claims.Expiry = claims.IssuedAt + assertionMaxAge + 1
+	if verifyAssertion(key, keys, claims) == nil {
+		t.Errorf("accepted assertion that expires too late")
+	}
+
+	claims = genAssertion()
+	claims.Issuer = "other-client"
+	if verifyAssertion(key, keys, claims) == nil {
+		t.Errorf("accepted assertion whose issuer is not its subject")
+	}
+
+	claims = genAssertion()
+	claims.ID = ""
+	if verifyAssertion(key, keys, claims) == nil {
+		t.Errorf("accepted assertion without jti")
+	}
+}
+
+func TestVerifyClientAssertionBadSignature(t *testing.T) {
+	key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := JWKSet{Keys: []JWK{key.JWK()}}
+	if verifyAssertion(other, keys, genAssertion()) == nil {
+		t.Errorf("accepted assertion signed by another key")
+	}
+
+	if verifyAssertion(nil, keys, genAssertion()) == nil {
+		t.Errorf("accepted assertion with alg none")
+	}
+}
+
+func TestAudienceUnmarshal(t *testing.T) {
+	var claims ClientAssertionClaims
+	if err := json.Unmarshal([]byte(`{"aud":"a"}`), &claims); err != nil {
+		t.Fatal(err)
+	}
+	if len(claims.Audience) != 1 || claims.Audience[0] != "a" {
+		t.Fatalf("string audience decoded as %v", claims.Audience)
+	}
+
+	if err := json.Unmarshal([]byte(`{"aud":["a","b"]}`), &claims); err != nil {
+		t.Fatal(err)
+	}
+	if len(claims.Audience) != 2 || claims.Audience[1] != "b" {
+		t.Fatalf("array audience decoded as %v", claims.Audience)
+	}
+}
+
+func TestAudienceUnmarshalBadType(t *testing.T) {
+	var claims ClientAssertionClaims
+	if err := json.Unmarshal([]byte(`{"aud":1}`), &claims); err == nil {
+		t.Fatalf("numeric audience accepted")
+	}
+}
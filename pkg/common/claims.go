@@ -0,0 +1,81 @@
+package common
+
+// AccessTokenType is the "typ" header of JWT access tokens.
+// See: https://datatracker.ietf.org/doc/html/rfc9068
+const AccessTokenType = "at+jwt"
+
+// AccessTokenClaims are the claims of a self-contained JWT access token.
+// Subject is the user ID, or the client ID for tokens that a client
+// obtained on its own behalf.
+type AccessTokenClaims struct {
+	Issuer   string       `json:"iss"`
+	Subject  string       `json:"sub"`
+	Audience []string     `json:"aud,omitempty"`
+	ClientID string       `json:"client_id"`
+	Scope    string       `json:"scope"`
+	Realms   []string     `json:"realms,omitempty"`
+	Actor    *ActorClaims `json:"act,omitempty"`
+	Confirm  *Confirm     `json:"cnf,omitempty"`
+	Expiry   int64        `json:"exp"`
+	IssuedAt int64        `json:"iat"`
+	ID       string       `json:"jti"`
+}
+
+// ActorClaims identifies a client acting on behalf of the token subject.
+// Prior actors in a delegation chain are nested.
+// See: https://datatracker.ietf.org/doc/html/rfc8693#section-4.1
+type ActorClaims struct {
+	Subject string       `json:"sub"`
+	Actor   *ActorClaims `json:"act,omitempty"`
+}
+
+// NewActorClaims nests a chain of actor IDs, the current actor first.
+// Returns nil for an empty chain.
+func NewActorClaims(actors []string) *ActorClaims {
+	var act *ActorClaims
+	for i := len(actors) - 1; i >= 0; i-- {
+		act = &ActorClaims{Subject: actors[i], Actor: act}
+	}
+	return act
+}
+
+// Chain flattens nested actor claims, the current actor first.
+func (a *ActorClaims) Chain() []string {
+	actors := []string{}
+	for act := a; act != nil; act = act.Actor {
+		actors = append(actors, act.Subject)
+	}
+	return actors
+}
+
+// Confirm binds a token to a key that the client must prove possession of
+// when presenting it, either a DPoP key or a client certificate.
+// See: https://datatracker.ietf.org/doc/html/rfc9449#section-6.1
+type Confirm struct {
+	JKT string `json:"jkt,omitempty"`
+
+	// X5T is the thumbprint of the client certificate that the token
+	// is bound to.
+	// See: https://datatracker.ietf.org/doc/html/rfc8705#section-3.1
+	X5T string `json:"x5t#S256,omitempty"`
+}
+
+// LogoutTokenType is the "typ" header of back-channel logout tokens.
+// See: https://openid.net/specs/openid-connect-backchannel-1_0.html#LogoutToken
+const LogoutTokenType = "logout+jwt"
+
+// BackchannelLogoutEvent is the event that identifies a JWT as a logout
+// token.
+const BackchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// LogoutTokenClaims are the claims of a back-channel logout token, which
+// tells a client that the subject has signed out.
+type LogoutTokenClaims struct {
+	Issuer   string              `json:"iss"`
+	Subject  string              `json:"sub"`
+	Audience string              `json:"aud"`
+	IssuedAt int64               `json:"iat"`
+	Expiry   int64               `json:"exp"`
+	ID       string              `json:"jti"`
+	Events   map[string]struct{} `json:"events"`
+}
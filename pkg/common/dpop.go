@@ -0,0 +1,116 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// DPoPProofType is the "typ" header of DPoP proofs.
+// See: https://datatracker.ietf.org/doc/html/rfc9449#section-4.2
+const DPoPProofType = "dpop+jwt"
+
+// DPoPMaxAge is the number of seconds that a DPoP proof or nonce is
+// accepted for.
+const DPoPMaxAge = 300
+
+// DPoPSigningAlgs are the algorithms that DPoP proofs can be signed with.
+var DPoPSigningAlgs = []string{"ES256", "EdDSA", "RS256"}
+
+// DPoPClaims are the claims of a DPoP proof. AccessTokenHash is only set
+// on proofs that accompany an access token.
+type DPoPClaims struct {
+	ID              string `json:"jti"`
+	Method          string `json:"htm"`
+	URL             string `json:"htu"`
+	IssuedAt        int64  `json:"iat"`
+	Nonce           string `json:"nonce,omitempty"`
+	AccessTokenHash string `json:"ath,omitempty"`
+}
+
+// VerifyDPoP verifies a DPoP proof for a request with the given method
+// and URL, using the public key embedded in the proof's "jwk" header.
+// Returns the proof's claims and the JWK thumbprint of its key, which
+// tokens are bound to. Nonces are checked by the caller.
+func VerifyDPoP(proof, method, url string, now int64) (DPoPClaims, string, error) {
+	var claims DPoPClaims
+	header, err := DecodeJWT(proof, &claims)
+	if err != nil {
+		return claims, "", err
+	}
+
+	if header.Typ != DPoPProofType || header.JWK == nil {
+		return claims, "", fmt.Errorf("DPoP proof must have typ %s and a jwk header", DPoPProofType)
+	}
+
+	key, err := header.JWK.PublicKey()
+	if err != nil {
+		return claims, "", err
+	}
+
+	if _, err := VerifyJWT(proof, key, &claims); err != nil {
+		return claims, "", err
+	}
+
+	if claims.ID == "" {
+		return claims, "", fmt.Errorf("DPoP proof is missing jti")
+	}
+
+	if claims.Method != method || stripURL(claims.URL) != stripURL(url) {
+		return claims, "", fmt.Errorf("DPoP proof was not issued for this request")
+	}
+
+	if claims.IssuedAt < now-DPoPMaxAge || claims.IssuedAt > now+DPoPMaxAge {
+		return claims, "", fmt.Errorf("DPoP proof is expired or issued in the future")
+	}
+
+	jkt, err := header.JWK.Thumbprint()
+	if err != nil {
+		return claims, "", err
+	}
+
+	return claims, jkt, nil
+}
+
+// stripURL removes the query and fragment from a URL, which are not
+// compared when checking the "htu" claim.
+func stripURL(url string) string {
+	if idx := strings.IndexAny(url, "?#"); idx >= 0 {
+		return url[:idx]
+	}
+	return url
+}
+
+// AccessTokenHash computes the "ath" claim of a DPoP proof for token.
+func AccessTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// NewDPoPNonce returns the nonce that servers currently require in DPoP
+// proofs. Nonces are derived from key and a time window, so they do not
+// need to be stored.
+func NewDPoPNonce(key []byte, now int64) string {
+	return dpopNonce(key, now/DPoPMaxAge)
+}
+
+// VerifyDPoPNonce reports whether nonce was issued for the current or
+// previous time window.
+func VerifyDPoPNonce(key []byte, nonce string, now int64) bool {
+	window := now / DPoPMaxAge
+	for _, valid := range []string{dpopNonce(key, window), dpopNonce(key, window-1)} {
+		if hmac.Equal([]byte(nonce), []byte(valid)) {
+			return true
+		}
+	}
+	return false
+}
+
+func dpopNonce(key []byte, window int64) string {
+	mac := hmac.New(sha256.New, key)
+	binary.Write(mac, binary.BigEndian, window)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,192 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+const (
+	proofMethod = "POST"
+	proofURL    = "https://auth.example.com/auth/token"
+)
+
+// genDPoPProof signs a proof for the token endpoint with key, embedding
+// its public key, and applies change to the claims first.
+func genDPoPProof(key *SigningKey, change func(*DPoPClaims)) string {
+	jwk := key.JWK()
+	claims := DPoPClaims{
+		ID:       "proof-1",
+		Method:   proofMethod,
+		URL:      proofURL,
+		IssuedAt: time.Now().Unix(),
+	}
+	if change != nil {
+		change(&claims)
+	}
+	return signJWT(key, JWTHeader{Alg: "ES256", Typ: DPoPProofType, JWK: &jwk}, claims)
+}
+
+func TestJWKThumbprint(t *testing.T) {
+	// Example from RFC 7638, Section 3.1.
+	jwk := JWK{
+		Kty: "RSA",
+		Kid: "2011-04-29",
+		Alg: "RS256",
+		N:   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:   "AQAB",
+	}
+
+	got, err := jwk.Thumbprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Fatalf("thumbprint does not match RFC 7638, got %q", got)
+	}
+
+	// Optional members do not change the thumbprint.
+	jwk.Kid, jwk.Alg, jwk.Use = "", "", "sig"
+	if again, _ := jwk.Thumbprint(); again != got {
+		t.Fatalf("thumbprint depends on optional members")
+	}
+}
+
+func TestJWKThumbprintBadKeyType(t *testing.T) {
+	if _, err := (JWK{Kty: "oct"}).Thumbprint(); err == nil {
+		t.Fatalf("thumbprint computed for unsupported key type")
+	}
+}
+
+func TestVerifyDPoP(t *testing.T) {
+	key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwk := key.JWK()
+	jkt, _ := jwk.Thumbprint()
+	now := time.Now().Unix()
+
+	proof := genDPoPProof(key, nil)
+	claims, thumbprint, err := VerifyDPoP(proof, proofMethod, proofURL, now)
+	if err != nil {
+		t.Fatalf("valid proof rejected: %s", err)
+	}
+
+	if thumbprint != jkt || claims.ID != "proof-1" {
+		t.Fatalf("unexpected proof %+v with thumbprint %q", claims, thumbprint)
+	}
+
+	// The query and fragment of the URL are not compared.
+	proof = genDPoPProof(key, func(c *DPoPClaims) { c.URL = proofURL + "?state=1" })
+	if _, _, err := VerifyDPoP(proof, proofMethod, proofURL, now); err != nil {
+		t.Fatalf("proof with query rejected: %s", err)
+	}
+}
+
+func TestVerifyDPoPWrongRequest(t *testing.T) {
+	key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Unix()
+	proof := genDPoPProof(key, func(c *DPoPClaims) { c.Method = "GET" })
+	if _, _, err := VerifyDPoP(proof, proofMethod, proofURL, now); err == nil {
+		t.Errorf("accepted proof for another method")
+	}
+
+	proof = genDPoPProof(key, func(c *DPoPClaims) {
+		c.URL = "https://auth.example.com/auth/revoke"
+	})
+	if _, _, err := VerifyDPoP(proof, proofMethod, proofURL, now); err == nil {
+		t.Errorf("accepted proof for another URL")
+	}
+}
+
+func TestVerifyDPoPBadTime(t *testing.T) {
+	key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Unix()
+	proof := genDPoPProof(key, nil)
+	if _, _, err := VerifyDPoP(proof, proofMethod, proofURL, now+DPoPMaxAge+1); err == nil {
+		t.Errorf("accepted expired proof")
+	}
+
+	if _, _, err := VerifyDPoP(proof, proofMethod, proofURL, now-DPoPMaxAge-1); err == nil {
+		t.Errorf("accepted proof issued in the future")
+	}
+}
+
+func TestVerifyDPoPBadProof(t *testing.T) {
+	key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Unix()
+	proof := genDPoPProof(key, func(c *DPoPClaims) { c.ID = "" })
+	if _, _, err := VerifyDPoP(proof, proofMethod, proofURL, now); err == nil {
+		t.Errorf("accepted proof without jti")
+	}
+
+	jwk := key.JWK()
+	claims := DPoPClaims{ID: "1", Method: proofMethod, URL: proofURL, IssuedAt: now}
+	for _, header := range []JWTHeader{
+		{Alg: "ES256", Typ: "JWT", JWK: &jwk},
+		{Alg: "ES256", Typ: DPoPProofType},
+	} {
+		proof = signJWT(key, header, claims)
+		if _, _, err := VerifyDPoP(proof, proofMethod, proofURL, now); err == nil {
+			t.Errorf("accepted proof with header %+v", header)
+		}
+	}
+
+	proof = signJWT(other, JWTHeader{Alg: "ES256", Typ: DPoPProofType, JWK: &jwk}, claims)
+	if _, _, err := VerifyDPoP(proof, proofMethod, proofURL, now); err == nil {
+		t.Errorf("accepted proof signed by another key")
+	}
+
+	proof = signJWT(nil, JWTHeader{Typ: DPoPProofType, JWK: &jwk}, claims)
+	if _, _, err := VerifyDPoP(proof, proofMethod, proofURL, now); err == nil {
+		t.Errorf("accepted proof with alg none")
+	}
+}
+
+func TestVerifyDPoPNonce(t *testing.T) {
+	key := []byte("nonce-key")
+	now := time.Now().Unix()
+	nonce := NewDPoPNonce(key, now)
+
+	if !VerifyDPoPNonce(key, nonce, now) {
+		t.Errorf("rejected nonce of the current window")
+	}
+	if !VerifyDPoPNonce(key, nonce, now+DPoPMaxAge) {
+		t.Errorf("rejected nonce of the previous window")
+	}
+}
+
+func TestVerifyDPoPNonceBadNonce(t *testing.T) {
+	key := []byte("nonce-key")
+	now := time.Now().Unix()
+	nonce := NewDPoPNonce(key, now)
+
+	if VerifyDPoPNonce(key, nonce, now+2*DPoPMaxAge) {
+		t.Errorf("accepted expired nonce")
+	}
+	if VerifyDPoPNonce([]byte("other-key"), nonce, now) {
+		t.Errorf("accepted nonce issued with another key")
+	}
+	if VerifyDPoPNonce(key, "", now) {
+		t.Errorf("accepted empty nonce")
+	}
+}
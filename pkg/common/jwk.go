@@ -0,0 +1,226 @@
+package common
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// JWK is a JSON Web Key holding a public key.
+// See: https://datatracker.ietf.org/doc/html/rfc7517
+type JWK struct {
+	Kty string `json:"kty" bson:"kty"`
+	Kid string `json:"kid,omitempty" bson:"kid,omitempty"`
+	Use string `json:"use,omitempty" bson:"use,omitempty"`
+	Alg string `json:"alg,omitempty" bson:"alg,omitempty"`
+	Crv string `json:"crv,omitempty" bson:"crv,omitempty"`
+	X   string `json:"x,omitempty" bson:"x,omitempty"`
+	Y   string `json:"y,omitempty" bson:"y,omitempty"`
+	N   string `json:"n,omitempty" bson:"n,omitempty"`
+	E   string `json:"e,omitempty" bson:"e,omitempty"`
+}
+
+// JWKSet is a JSON Web Key Set.
+type JWKSet struct {
+	Keys []JWK `json:"keys" bson:"keys"`
+}
+
+// NewJWK encodes an ECDSA P-256, Ed25519 or RSA public key as a JWK.
+func NewJWK(pub crypto.PublicKey) (JWK, error) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return JWK{}, fmt.Errorf("unsupported elliptic curve")
+		}
+		x := make([]byte, 32)
+		y := make([]byte, 32)
+		key.X.FillBytes(x)
+		key.Y.FillBytes(y)
+		return JWK{Kty: "EC", Crv: "P-256", X: b64(x), Y: b64(y)}, nil
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Crv: "Ed25519", X: b64(key)}, nil
+	case *rsa.PublicKey:
+		e := big.NewInt(int64(key.E)).Bytes()
+		return JWK{Kty: "RSA", N: b64(key.N.Bytes()), E: b64(e)}, nil
+	}
+	return JWK{}, fmt.Errorf("unsupported key type")
+}
+
+// PublicKey decodes the JWK into a crypto.PublicKey.
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	dec := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported elliptic curve")
+		}
+		x, err := dec(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := dec(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("invalid elliptic curve point")
+		}
+		return key, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve")
+		}
+		x, err := dec(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	case "RSA":
+		n, err := dec(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := dec(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type")
+}
+
+// Thumbprint computes the base64url-encoded SHA-256 JWK thumbprint.
+// See: https://datatracker.ietf.org/doc/html/rfc7638
+func (k JWK) Thumbprint() (string, error) {
+	// Members must be in lexicographic order with no whitespace.
+	var input string
+	switch k.Kty {
+	case "EC":
+		input = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`, k.Crv, k.X, k.Y)
+	case "OKP":
+		input = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, k.Crv, k.X)
+	case "RSA":
+		input = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, k.E, k.N)
+	default:
+		return "", fmt.Errorf("unsupported key type")
+	}
+	sum := sha256.Sum256([]byte(input))
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// Find returns the key with the given key ID. If kid is empty and the set
+// holds exactly one key, that key is returned.
+func (s JWKSet) Find(kid string) (JWK, bool) {
+	if kid == "" && len(s.Keys) == 1 {
+		return s.Keys[0], true
+	}
+	for _, key := range s.Keys {
+		if key.Kid == kid {
+			return key, true
+		}
+	}
+	return JWK{}, false
+}
+
+// SigningKey is a private key used by the server to sign JSON Web Tokens.
+type SigningKey struct {
+	Key   crypto.Signer
+	KeyID string
+	Alg   string
+}
+
+// NewSigningKey wraps an ECDSA P-256, Ed25519 or RSA private key. The key ID
+// is derived from the public key thumbprint.
+func NewSigningKey(key crypto.Signer) (*SigningKey, error) {
+	jwk, err := NewJWK(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	kid, err := jwk.Thumbprint()
+	if err != nil {
+		return nil, err
+	}
+
+	sk := &SigningKey{Key: key, KeyID: kid}
+	switch key.(type) {
+	case *ecdsa.PrivateKey:
+		sk.Alg = "ES256"
+	case ed25519.PrivateKey:
+		sk.Alg = "EdDSA"
+	case *rsa.PrivateKey:
+		sk.Alg = "RS256"
+	}
+	return sk, nil
+}
+
+// GenerateSigningKey creates a new random ECDSA P-256 signing key.
+func GenerateSigningKey() (*SigningKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigningKey(key)
+}
+
+// LoadSigningKey reads a PEM-encoded PKCS #8, SEC 1 or PKCS #1 private key
+// from the file at path.
+func LoadSigningKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type")
+	}
+	return NewSigningKey(signer)
+}
+
+// JWK returns the public part of the signing key.
+func (k *SigningKey) JWK() JWK {
+	jwk, _ := NewJWK(k.Key.Public())
+	jwk.Kid = k.KeyID
+	jwk.Use = "sig"
+	jwk.Alg = k.Alg
+	return jwk
+}
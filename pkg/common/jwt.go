@@ -0,0 +1,162 @@
+package common
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// JWTHeader is the JOSE header of a JSON Web Token.
+type JWTHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	JWK *JWK   `json:"jwk,omitempty"`
+}
+
+// Sign encodes claims as a JWT of the given type ("typ" header) and signs
+// it with the key.
+func (k *SigningKey) Sign(typ string, claims interface{}) (string, error) {
+	header, err := json.Marshal(JWTHeader{Alg: k.Alg, Typ: typ, Kid: k.KeyID})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	input := b64(header) + "." + b64(payload)
+	sum := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	switch key := k.Key.(type) {
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, sum[:])
+		if err != nil {
+			return "", err
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(key, []byte(input))
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("unsupported key type")
+	}
+
+	return input + "." + b64(sig), nil
+}
+
+// IsJWT reports whether token has the compact serialization of a JWS.
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// DecodeJWT decodes the header and claims of a JWT without verifying its
+// signature. Callers must not trust the claims until VerifyJWT succeeds.
+func DecodeJWT(token string, claims interface{}) (JWTHeader, error) {
+	var header JWTHeader
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return header, fmt.Errorf("malformed JWT")
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return header, fmt.Errorf("malformed JWT header")
+	}
+
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return header, fmt.Errorf("malformed JWT header")
+	}
+
+	raw, err = base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return header, fmt.Errorf("malformed JWT payload")
+	}
+
+	if err := json.Unmarshal(raw, claims); err != nil {
+		return header, fmt.Errorf("malformed JWT payload")
+	}
+
+	return header, nil
+}
+
+// VerifyJWT verifies the signature of token using key and decodes its
+// claims. The "alg" header must match the type of key; "none" is never
+// accepted.
+func VerifyJWT(token string, key crypto.PublicKey, claims interface{}) (JWTHeader, error) {
+	header, err := DecodeJWT(token, claims)
+	if err != nil {
+		return header, err
+	}
+
+	idx := strings.LastIndex(token, ".")
+	input := token[:idx]
+	sig, err := base64.RawURLEncoding.DecodeString(token[idx+1:])
+	if err != nil {
+		return header, fmt.Errorf("malformed JWT signature")
+	}
+
+	sum := sha256.Sum256([]byte(input))
+	valid := false
+	switch pub := key.(type) {
+	case *ecdsa.PublicKey:
+		if header.Alg == "ES256" && len(sig) == 64 {
+			r := new(big.Int).SetBytes(sig[:32])
+			s := new(big.Int).SetBytes(sig[32:])
+			valid = ecdsa.Verify(pub, sum[:], r, s)
+		}
+	case ed25519.PublicKey:
+		if header.Alg == "EdDSA" {
+			valid = ed25519.Verify(pub, []byte(input), sig)
+		}
+	case *rsa.PublicKey:
+		if header.Alg == "RS256" {
+			valid = rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig) == nil
+		}
+	}
+
+	if !valid {
+		return header, fmt.Errorf("invalid JWT signature")
+	}
+	return header, nil
+}
+
+// VerifyJWTWithSet verifies token using the key in set identified by the
+// token's "kid" header.
+func VerifyJWTWithSet(token string, set JWKSet, claims interface{}) (JWTHeader, error) {
+	var discard json.RawMessage
+	header, err := DecodeJWT(token, &discard)
+	if err != nil {
+		return header, err
+	}
+
+	jwk, ok := set.Find(header.Kid)
+	if !ok {
+		return header, fmt.Errorf("unknown JWT key ID")
+	}
+
+	key, err := jwk.PublicKey()
+	if err != nil {
+		return header, err
+	}
+
+	return VerifyJWT(token, key, claims)
+}
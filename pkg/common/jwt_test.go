@@ -0,0 +1,150 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+)
+
+// testClaims are the claims of tokens signed in tests.
+type testClaims struct {
+	Subject string `json:"sub"`
+	Expiry  int64  `json:"exp,omitempty"`
+}
+
+// signJWT signs claims with key under an arbitrary header, which
+// SigningKey.Sign does not allow. Signs with alg "none" if key is nil.
+func signJWT(key *SigningKey, header JWTHeader, claims interface{}) string {
+	if key == nil {
+		header.Alg = "none"
+	}
+
+	rawHeader, _ := json.Marshal(header)
+	rawClaims, _ := json.Marshal(claims)
+	b64 := base64.RawURLEncoding.EncodeToString
+	input := b64(rawHeader) + "." + b64(rawClaims)
+	if key == nil {
+		return input + "."
+	}
+
+	sum := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key.Key.(*ecdsa.PrivateKey), sum[:])
+	if err != nil {
+		panic(err)
+	}
+
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return input + "." + b64(sig)
+}
+
+func TestSignJWT(t *testing.T) {
+	key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := key.Sign("at+jwt", testClaims{Subject: "client"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsJWT(token) {
+		t.Fatalf("signed token %q is not a compact JWS", token)
+	}
+
+	var claims testClaims
+	header, err := VerifyJWT(token, key.Key.Public(), &claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if header.Alg != "ES256" || header.Typ != "at+jwt" || header.Kid != key.KeyID {
+		t.Fatalf("unexpected header %+v", header)
+	}
+
+	if claims.Subject != "client" {
+		t.Fatalf("sub claim not preserved, got %q", claims.Subject)
+	}
+}
+
+func TestVerifyJWTWithSet(t *testing.T) {
+	key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := key.Sign("at+jwt", testClaims{Subject: "client"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var claims testClaims
+	set := JWKSet{Keys: []JWK{key.JWK()}}
+	if _, err := VerifyJWTWithSet(token, set, &claims); err != nil {
+		t.Fatalf("valid token rejected: %s", err)
+	}
+}
+
+func TestVerifyJWTWithSetBadAlg(t *testing.T) {
+	key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var claims testClaims
+	set := JWKSet{Keys: []JWK{key.JWK()}}
+	unsigned := signJWT(nil, JWTHeader{Kid: key.KeyID}, testClaims{Subject: "client"})
+	if _, err := VerifyJWTWithSet(unsigned, set, &claims); err == nil {
+		t.Errorf("accepted token with alg none")
+	}
+
+	rs256 := signJWT(key, JWTHeader{Alg: "RS256", Kid: key.KeyID},
+		testClaims{Subject: "client"})
+	if _, err := VerifyJWTWithSet(rs256, set, &claims); err == nil {
+		t.Errorf("accepted token whose alg does not match the key")
+	}
+}
+
+func TestVerifyJWTWithSetBadSignature(t *testing.T) {
+	key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var claims testClaims
+	set := JWKSet{Keys: []JWK{key.JWK()}}
+
+	// Signed by another key under the trusted key ID.
+	forged := signJWT(other, JWTHeader{Alg: "ES256", Kid: key.KeyID},
+		testClaims{Subject: "client"})
+	if _, err := VerifyJWTWithSet(forged, set, &claims); err == nil {
+		t.Errorf("accepted token signed by another key")
+	}
+
+	// Payload replaced after signing.
+	valid, _ := key.Sign("at+jwt", testClaims{Subject: "client"})
+	tampered, _ := key.Sign("at+jwt", testClaims{Subject: "attacker"})
+	tampered = tampered[:len(tampered)-86] + valid[len(valid)-86:]
+	if _, err := VerifyJWTWithSet(tampered, set, &claims); err == nil {
+		t.Errorf("accepted token with tampered payload")
+	}
+
+	unknown, _ := other.Sign("at+jwt", testClaims{Subject: "client"})
+	if _, err := VerifyJWTWithSet(unknown, set, &claims); err == nil {
+		t.Errorf("accepted token with unknown key ID")
+	}
+
+	if _, err := VerifyJWTWithSet("not.a-jwt", set, &claims); err == nil {
+		t.Errorf("accepted malformed token")
+	}
+}
@@ -0,0 +1,34 @@
+package common
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+)
+
+// CertificateThumbprint computes the "x5t#S256" confirmation method of a
+// client certificate, which certificate-bound tokens carry.
+// See: https://datatracker.ietf.org/doc/html/rfc8705#section-3.1
+func CertificateThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// ParseForwardedCertificate parses a client certificate that a TLS
+// terminating proxy forwarded in a request header, as a PEM block that may
+// be URL-encoded.
+func ParseForwardedCertificate(value string) (*x509.Certificate, error) {
+	if unescaped, err := url.QueryUnescape(value); err == nil {
+		value = unescaped
+	}
+
+	block, _ := pem.Decode([]byte(value))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("forwarded client certificate is not a PEM certificate")
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
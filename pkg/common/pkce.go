@@ -0,0 +1,44 @@
+package common
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"regexp"
+)
+
+// PKCE code challenge methods.
+// See: https://datatracker.ietf.org/doc/html/rfc7636#section-4.2
+const (
+	PKCEPlain = "plain"
+	PKCES256  = "S256"
+)
+
+var pkceRegex = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+// ValidateCodeChallenge checks whether a PKCE code challenge and its
+// transformation method are well-formed.
+func ValidateCodeChallenge(method, challenge string) bool {
+	if method != PKCEPlain && method != PKCES256 {
+		return false
+	}
+	return pkceRegex.MatchString(challenge)
+}
+
+// VerifyCodeVerifier checks whether verifier is the secret from which
+// challenge was derived using the given method.
+func VerifyCodeVerifier(method, challenge, verifier string) bool {
+	if !pkceRegex.MatchString(verifier) {
+		return false
+	}
+
+	derived := verifier
+	if method == PKCES256 {
+		sum := sha256.Sum256([]byte(verifier))
+		derived = base64.RawURLEncoding.EncodeToString(sum[:])
+	} else if method != PKCEPlain {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(derived), []byte(challenge)) == 1
+}
@@ -0,0 +1,69 @@
+package common
+
+import "testing"
+
+// Example from RFC 7636, Appendix B.
+const (
+	testVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+func TestValidateCodeChallenge(t *testing.T) {
+	if !ValidateCodeChallenge(PKCES256, testChallenge) {
+		t.Errorf("rejected S256 challenge")
+	}
+	if !ValidateCodeChallenge(PKCEPlain, testVerifier) {
+		t.Errorf("rejected plain challenge")
+	}
+}
+
+func TestValidateCodeChallengeBadMethod(t *testing.T) {
+	if ValidateCodeChallenge("S512", testChallenge) {
+		t.Errorf("accepted unknown challenge method")
+	}
+	if ValidateCodeChallenge("", testChallenge) {
+		t.Errorf("accepted empty challenge method")
+	}
+}
+
+func TestValidateCodeChallengeBadChallenge(t *testing.T) {
+	if ValidateCodeChallenge(PKCES256, "abc") {
+		t.Errorf("accepted challenge shorter than 43 characters")
+	}
+	if ValidateCodeChallenge(PKCES256, testChallenge[:42]+"+") {
+		t.Errorf("accepted challenge with characters outside the alphabet")
+	}
+}
+
+func TestVerifyCodeVerifier(t *testing.T) {
+	if !VerifyCodeVerifier(PKCES256, testChallenge, testVerifier) {
+		t.Errorf("rejected S256 verifier")
+	}
+	if !VerifyCodeVerifier(PKCEPlain, testVerifier, testVerifier) {
+		t.Errorf("rejected plain verifier")
+	}
+}
+
+func TestVerifyCodeVerifierMismatch(t *testing.T) {
+	if VerifyCodeVerifier(PKCES256, testChallenge, testVerifier[:42]+"x") {
+		t.Errorf("accepted wrong verifier")
+	}
+	if VerifyCodeVerifier(PKCES256, testChallenge, testChallenge) {
+		t.Errorf("accepted challenge as its own verifier")
+	}
+	if VerifyCodeVerifier(PKCEPlain, testChallenge, testVerifier) {
+		t.Errorf("accepted S256 verifier for a plain challenge")
+	}
+}
+
+func TestVerifyCodeVerifierBadVerifier(t *testing.T) {
+	if VerifyCodeVerifier("S512", testChallenge, testVerifier) {
+		t.Errorf("accepted unknown challenge method")
+	}
+	if VerifyCodeVerifier(PKCEPlain, "abc", "abc") {
+		t.Errorf("accepted verifier shorter than 43 characters")
+	}
+	if VerifyCodeVerifier(PKCES256, testChallenge, "") {
+		t.Errorf("accepted empty verifier")
+	}
+}
@@ -0,0 +1,47 @@
+package common
+
+import (
+	"net"
+	"net/url"
+)
+
+// isLoopback reports whether host is a loopback IP literal or localhost.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// MatchRedirectURI reports whether uri is one of the registered redirect
+// URIs. Registered URIs are compared by exact string match, except for
+// http loopback redirects whose port is ignored, as native clients
+// listen on an ephemeral port.
+// See: https://datatracker.ietf.org/doc/html/rfc8252#section-7.3
+func MatchRedirectURI(registered []string, uri string) bool {
+	for _, r := range registered {
+		if r == uri {
+			return true
+		}
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "http" || !isLoopback(u.Hostname()) {
+		return false
+	}
+
+	for _, r := range registered {
+		ru, err := url.Parse(r)
+		if err != nil || ru.Scheme != "http" {
+			continue
+		}
+
+		if ru.Hostname() == u.Hostname() && ru.Path == u.Path &&
+			ru.RawQuery == u.RawQuery && u.Fragment == "" && u.User == nil {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,60 @@
+package common
+
+import "testing"
+
+var registeredURIs = []string{
+	"https://app.example.com/callback",
+	"http://127.0.0.1:8000/callback",
+	"http://[::1]/callback",
+	"http://localhost:3000/cb?app=1",
+}
+
+func TestMatchRedirectURI(t *testing.T) {
+	if !MatchRedirectURI(registeredURIs, "https://app.example.com/callback") {
+		t.Errorf("rejected registered https URI")
+	}
+	if !MatchRedirectURI(registeredURIs, "http://127.0.0.1:8000/callback") {
+		t.Errorf("rejected registered loopback URI")
+	}
+}
+
+func TestMatchRedirectURILoopbackPort(t *testing.T) {
+	for _, uri := range []string{
+		"http://127.0.0.1:51004/callback",
+		"http://127.0.0.1/callback",
+		"http://[::1]:6060/callback",
+		"http://localhost:4000/cb?app=1",
+	} {
+		if !MatchRedirectURI(registeredURIs, uri) {
+			t.Errorf("rejected loopback URI %s on another port", uri)
+		}
+	}
+}
+
+func TestMatchRedirectURIBadLoopback(t *testing.T) {
+	for _, uri := range []string{
+		"http://127.0.0.1:51004/other",
+		"http://localhost:4000/cb?app=2",
+		"http://localhost:8000/callback",
+		"http://127.0.0.1:51004/callback#frag",
+		"http://user@127.0.0.1:51004/callback",
+		"https://127.0.0.1:51004/callback",
+		"http://192.168.0.1:8000/callback",
+	} {
+		if MatchRedirectURI(registeredURIs, uri) {
+			t.Errorf("accepted unregistered loopback URI %s", uri)
+		}
+	}
+}
+
+func TestMatchRedirectURIExact(t *testing.T) {
+	for _, uri := range []string{
+		"https://app.example.com:8443/callback",
+		"https://app.example.com/callback/other",
+		"https://evil.example.com/callback",
+	} {
+		if MatchRedirectURI(registeredURIs, uri) {
+			t.Errorf("accepted unregistered URI %s", uri)
+		}
+	}
+}
@@ -1,7 +1,29 @@
 package common
 
-import "github.com/google/uuid"
+import (
+	"crypto/rand"
+	"github.com/google/uuid"
+	"math/big"
+)
 
 func UUID() string {
 	return uuid.New().String()
 }
+
+// userCodeCharset excludes vowels (to avoid spelling words) and easily
+// confused characters. See: https://datatracker.ietf.org/doc/html/rfc8628#section-6.1
+const userCodeCharset = "BCDFGHJKLMNPQRSTVWXZ"
+
+// UserCode returns a random 8-character device flow user code.
+func UserCode() (string, error) {
+	code := make([]byte, 8)
+	max := big.NewInt(int64(len(userCodeCharset)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeCharset[n.Int64()]
+	}
+	return string(code), nil
+}
@@ -6,6 +6,7 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"net/mail"
 	"regexp"
+	"sort"
 )
 
 // ValidateEmail checks whether email is a valid email address.
@@ -35,59 +36,72 @@ func VerifyPassword(hash, password string) bool {
 	return true
 }
 
-// ValidateTokenScope validates a scope slice for a client that uses the 'token'
-// authentication response type.
-func validateTokenScope(scope []string) bool {
-	// Token-based clients only have access to 'public' scope.
-	if len(scope) > 1 {
-		return false
-	}
-
-	if scope[0] != "public" {
-		return false
-	}
-
-	return true
+// clientScopes maps each response type that a client can register to the
+// scope that such clients may request.
+var clientScopes = map[string][]string{
+	"code":  {"public", "email", "profile"},
+	"token": {"public"},
 }
 
-// ValidateCodeScope validates a scope slice for a client that uses the 'code'
-// authentication response type.
-func validateCodeScope(scope []string) bool {
-	if len(scope) > 2 {
-		return false
+// ResponseTypes returns the response types that a client can register.
+func ResponseTypes() []string {
+	types := []string{}
+	for resType := range clientScopes {
+		types = append(types, resType)
 	}
+	sort.Strings(types)
+	return types
+}
 
-	// Must be either 'public' or 'email'
-	for _, v := range scope {
-		if v != "public" && v != "email" {
-			return false
+// Scopes returns every scope that a client can register.
+func Scopes() []string {
+	scopes := []string{}
+	for _, resType := range ResponseTypes() {
+		for _, v := range clientScopes[resType] {
+			if !contains(scopes, v) {
+				scopes = append(scopes, v)
+			}
 		}
 	}
+	return scopes
+}
 
-	return true
+// ValidateResponseType checks whether a client can register resType.
+func ValidateResponseType(resType string) bool {
+	_, ok := clientScopes[resType]
+	return ok
 }
 
 // ValidateScope checks whether the scope string is valid for the given response
 // type.
 func ValidateScope(resType string, scope []string) bool {
-	if len(scope) == 0 {
+	allowed := clientScopes[resType]
+	if len(scope) == 0 || len(scope) > len(allowed) {
 		return false
 	}
 
-	if resType == "token" {
-		return validateTokenScope(scope)
+	for _, v := range scope {
+		if !contains(allowed, v) {
+			return false
+		}
 	}
 
-	if resType == "code" {
-		return validateCodeScope(scope)
-	}
+	return true
+}
 
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
 	return false
 }
 
-var redirectURIRegex = regexp.MustCompile(`^(https|http):\/\/([a-z0-9]|\.|\-|\_)*(\/[a-z0-9A-Z:@?=&%.\-_$+]+)*$`)
+var redirectURIRegex = regexp.MustCompile(`^(https|http):\/\/(([a-z0-9]|\.|\-|\_)*|\[::1\])(:[0-9]{1,5})?(\/[a-z0-9A-Z:@?=&%.\-_$+]+)*$`)
 
-// ValidateRedirectURI validates a client redirect URI.
+// ValidateRedirectURI validates a client redirect URI. A port may be given,
+// though loopback redirects should be registered without one.
 func ValidateRedirectURI(uri string) bool {
 	return redirectURIRegex.MatchString(uri)
 }